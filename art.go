@@ -0,0 +1,767 @@
+package trie
+
+import (
+	"bytes"
+	"math"
+)
+
+// An adaptive radix tree, as described in "The Adaptive Radix Tree: ARTful
+// Indexing for Main-Memory Databases" by Leis et al. Like the radix trie it
+// compresses paths, but each node also picks how to store its children based on
+// how many it has. Sparse nodes keep a small sorted array that we can scan
+// quickly, while dense nodes index their children directly by byte, so finding
+// the next child never turns into a linear scan over hundreds of entries.
+const (
+	node4 uint8 = iota
+	node16
+	node48
+	node256
+)
+
+type artNode struct {
+	kind uint8
+
+	// The bytes that come after the byte our parent knows us by. They're
+	// matched in one go before moving on to the children.
+	prefix []byte
+
+	value    interface{}
+	hasValue bool
+
+	// The number of keys stored in this subtree, including this node.
+	size int
+
+	// The weight of this node's key, and the biggest weight of any key in
+	// the subtree, so TopK knows which way to go.
+	weight    float64
+	maxWeight float64
+
+	// The number of children in use.
+	count int
+
+	// node4 and node16 keep their child bytes sorted here, in step with
+	// children. node48 uses all 256 entries as an index into children, off by
+	// one so that zero means empty. node256 doesn't need it at all.
+	keys     []byte
+	children []*artNode
+}
+
+type artTrie struct {
+	root *artNode
+}
+
+// NewART returns an empty Trie backed by an adaptive radix tree. It's the same
+// as New(WithART()).
+func NewART() Trie {
+	return New(WithART())
+}
+
+func (self *artNode) full() bool {
+	switch self.kind {
+	case node4:
+		return self.count == 4
+	case node16:
+		return self.count == 16
+	case node48:
+		return self.count == 48
+	}
+
+	return false
+}
+
+// index returns where b is in a node4 or node16, or where it would go if it
+// isn't there.
+func (self *artNode) index(b byte) int {
+	for i := 0; i < self.count; i++ {
+		if self.keys[i] >= b {
+			return i
+		}
+	}
+
+	return self.count
+}
+
+func (self *artNode) findChild(b byte) *artNode {
+	switch self.kind {
+	case node4, node16:
+		if i := self.index(b); i < self.count && self.keys[i] == b {
+			return self.children[i]
+		}
+	case node48:
+		if slot := self.keys[b]; slot > 0 {
+			return self.children[slot-1]
+		}
+	case node256:
+		return self.children[b]
+	}
+
+	return nil
+}
+
+// resize moves the children into the storage for kind.
+func (self *artNode) resize(kind uint8) {
+	keys := make([]byte, 0, 256)
+	children := make([]*artNode, 0, 256)
+
+	for pos := self.lowerBound(0); pos >= 0; pos = self.after(pos) {
+		b, child := self.childAt(pos)
+		keys = append(keys, b)
+		children = append(children, child)
+	}
+
+	self.kind = kind
+
+	switch kind {
+	case node4, node16:
+		size := 4
+
+		if kind == node16 {
+			size = 16
+		}
+
+		self.keys = make([]byte, size)
+		self.children = make([]*artNode, size)
+		copy(self.keys, keys)
+		copy(self.children, children)
+	case node48:
+		self.keys = make([]byte, 256)
+		self.children = make([]*artNode, 48)
+
+		for i, b := range keys {
+			self.keys[b] = byte(i + 1)
+			self.children[i] = children[i]
+		}
+	case node256:
+		self.keys = nil
+		self.children = make([]*artNode, 256)
+
+		for i, b := range keys {
+			self.children[b] = children[i]
+		}
+	}
+}
+
+func (self *artNode) addChild(b byte, child *artNode) {
+	// Leaves don't get any storage until they need it.
+	if self.children == nil {
+		self.keys = make([]byte, 4)
+		self.children = make([]*artNode, 4)
+	}
+
+	if self.full() {
+		self.resize(self.kind + 1)
+	}
+
+	switch self.kind {
+	case node4, node16:
+		i := self.index(b)
+		copy(self.keys[i+1:], self.keys[i:self.count])
+		copy(self.children[i+1:], self.children[i:self.count])
+		self.keys[i] = b
+		self.children[i] = child
+	case node48:
+		slot := 0
+
+		for self.children[slot] != nil {
+			slot++
+		}
+
+		self.children[slot] = child
+		self.keys[b] = byte(slot + 1)
+	case node256:
+		self.children[b] = child
+	}
+
+	self.count += 1
+}
+
+func (self *artNode) replaceChild(b byte, child *artNode) {
+	switch self.kind {
+	case node4, node16:
+		self.children[self.index(b)] = child
+	case node48:
+		self.children[self.keys[b]-1] = child
+	case node256:
+		self.children[b] = child
+	}
+}
+
+func (self *artNode) removeChild(b byte) {
+	switch self.kind {
+	case node4, node16:
+		i := self.index(b)
+		copy(self.keys[i:], self.keys[i+1:self.count])
+		copy(self.children[i:], self.children[i+1:self.count])
+		self.children[self.count-1] = nil
+	case node48:
+		self.children[self.keys[b]-1] = nil
+		self.keys[b] = 0
+	case node256:
+		self.children[b] = nil
+	}
+
+	self.count -= 1
+
+	// Shrink a bit later than we grow so that a node sitting right on the
+	// boundary doesn't flip back and forth.
+	switch {
+	case self.kind == node256 && self.count < 37:
+		self.resize(node48)
+	case self.kind == node48 && self.count < 12:
+		self.resize(node16)
+	case self.kind == node16 && self.count < 3:
+		self.resize(node4)
+	}
+}
+
+// Children are visited by position. For node4 and node16 that's the index
+// into keys, and for the bigger nodes it's the child's byte.
+
+func (self *artNode) childAt(pos int) (byte, *artNode) {
+	switch self.kind {
+	case node4, node16:
+		return self.keys[pos], self.children[pos]
+	case node48:
+		return byte(pos), self.children[self.keys[pos]-1]
+	}
+
+	return byte(pos), self.children[pos]
+}
+
+// lowerBound returns the position of the first child with a byte >= b, or -1
+// if there isn't one.
+func (self *artNode) lowerBound(b int) int {
+	switch self.kind {
+	case node4, node16:
+		for i := 0; i < self.count; i++ {
+			if int(self.keys[i]) >= b {
+				return i
+			}
+		}
+	case node48:
+		for ; b < 256; b++ {
+			if self.keys[b] != 0 {
+				return b
+			}
+		}
+	case node256:
+		for ; b < 256; b++ {
+			if self.children[b] != nil {
+				return b
+			}
+		}
+	}
+
+	return -1
+}
+
+// upperBound returns the position of the last child with a byte <= b, or -1 if
+// there isn't one.
+func (self *artNode) upperBound(b int) int {
+	switch self.kind {
+	case node4, node16:
+		for i := self.count - 1; i >= 0; i-- {
+			if int(self.keys[i]) <= b {
+				return i
+			}
+		}
+	case node48:
+		for ; b >= 0; b-- {
+			if self.keys[b] != 0 {
+				return b
+			}
+		}
+	case node256:
+		for ; b >= 0; b-- {
+			if self.children[b] != nil {
+				return b
+			}
+		}
+	}
+
+	return -1
+}
+
+func (self *artNode) after(pos int) int {
+	b, _ := self.childAt(pos)
+	return self.lowerBound(int(b) + 1)
+}
+
+func (self *artNode) before(pos int) int {
+	b, _ := self.childAt(pos)
+	return self.upperBound(int(b) - 1)
+}
+
+// insert stores val under key, which still includes this node's prefix.
+func (self *artNode) insert(key []byte, val interface{}, weight float64) bool {
+	common := commonPrefix(self.prefix, key)
+
+	// The key branches off part way through our prefix. Everything we have
+	// moves down a level, and we become a node4 holding the shared part.
+	if common < len(self.prefix) {
+		b := self.prefix[common]
+		old := *self
+		old.prefix = self.prefix[common+1:]
+
+		*self = artNode{prefix: self.prefix[:common], size: old.size, maxWeight: old.maxWeight}
+		self.addChild(b, &old)
+	}
+
+	key = key[common:]
+
+	if len(key) == 0 {
+		added := !self.hasValue
+		self.value = val
+		self.hasValue = true
+		self.weight = weight
+
+		if added {
+			self.size += 1
+		}
+
+		self.reweigh()
+		return added
+	}
+
+	child := self.findChild(key[0])
+
+	if child == nil {
+		self.addChild(key[0], &artNode{
+			prefix:    append([]byte{}, key[1:]...),
+			value:     val,
+			hasValue:  true,
+			size:      1,
+			weight:    weight,
+			maxWeight: weight,
+		})
+
+		self.size += 1
+		self.reweigh()
+		return true
+	}
+
+	added := child.insert(key[1:], val, weight)
+
+	if added {
+		self.size += 1
+	}
+
+	self.reweigh()
+	return added
+}
+
+// reweigh works out the node's maxWeight again after something underneath it
+// has changed.
+func (self *artNode) reweigh() {
+	best := math.Inf(-1)
+
+	if self.hasValue {
+		best = self.weight
+	}
+
+	for pos := self.lowerBound(0); pos >= 0; pos = self.after(pos) {
+		if _, child := self.childAt(pos); child.maxWeight > best {
+			best = child.maxWeight
+		}
+	}
+
+	self.maxWeight = best
+}
+
+func (self *artTrie) Insert(key []byte, val interface{}) {
+	self.root.insert(key, val, 0)
+}
+
+func (self *artTrie) InsertWeighted(key []byte, val interface{}, weight float64) {
+	self.root.insert(key, val, weight)
+}
+
+// find returns the node for key, or nil if there isn't one.
+func (self *artTrie) find(key []byte) *artNode {
+	node := self.root
+
+	for {
+		if !bytes.HasPrefix(key, node.prefix) {
+			return nil
+		}
+
+		key = key[len(node.prefix):]
+
+		if len(key) == 0 {
+			return node
+		}
+
+		node = node.findChild(key[0])
+
+		if node == nil {
+			return nil
+		}
+
+		key = key[1:]
+	}
+}
+
+func (self *artTrie) Get(key []byte) (interface{}, bool) {
+	node := self.find(key)
+
+	if node == nil {
+		return nil, false
+	}
+
+	return node.value, node.hasValue
+}
+
+// compact tidies up the child at b after something was removed from it, much
+// like radixNode.compact does.
+func (self *artNode) compact(b byte, child *artNode) {
+	if child.hasValue {
+		return
+	}
+
+	switch child.count {
+	case 0:
+		self.removeChild(b)
+	case 1:
+		gb, grandchild := child.childAt(child.lowerBound(0))
+		prefix := make([]byte, 0, len(child.prefix)+1+len(grandchild.prefix))
+		prefix = append(append(prefix, child.prefix...), gb)
+		grandchild.prefix = append(prefix, grandchild.prefix...)
+		self.replaceChild(b, grandchild)
+	}
+}
+
+func (self *artNode) delete(key []byte) bool {
+	if !bytes.HasPrefix(key, self.prefix) {
+		return false
+	}
+
+	key = key[len(self.prefix):]
+
+	if len(key) == 0 {
+		if !self.hasValue {
+			return false
+		}
+
+		self.value = nil
+		self.hasValue = false
+		self.weight = 0
+		self.size -= 1
+		self.reweigh()
+		return true
+	}
+
+	child := self.findChild(key[0])
+
+	if child == nil || !child.delete(key[1:]) {
+		return false
+	}
+
+	self.compact(key[0], child)
+	self.size -= 1
+	self.reweigh()
+	return true
+}
+
+func (self *artTrie) Delete(key []byte) bool {
+	return self.root.delete(key)
+}
+
+func (self *artNode) deletePrefix(prefix []byte) int {
+	common := commonPrefix(self.prefix, prefix)
+
+	// The prefix ends somewhere in our own prefix, so all of us goes.
+	if common == len(prefix) {
+		removed := self.size
+		*self = artNode{prefix: self.prefix, maxWeight: math.Inf(-1)}
+		return removed
+	}
+
+	if common < len(self.prefix) {
+		return 0
+	}
+
+	prefix = prefix[common:]
+	child := self.findChild(prefix[0])
+
+	if child == nil {
+		return 0
+	}
+
+	removed := child.deletePrefix(prefix[1:])
+
+	if removed > 0 {
+		self.compact(prefix[0], child)
+		self.size -= removed
+		self.reweigh()
+	}
+
+	return removed
+}
+
+func (self *artTrie) DeletePrefix(prefix []byte) int {
+	return self.root.deletePrefix(prefix)
+}
+
+func (self *artTrie) CountPrefix(prefix []byte) int {
+	node := self.root
+
+	for {
+		common := commonPrefix(node.prefix, prefix)
+
+		if common == len(prefix) {
+			return node.size
+		}
+
+		if common < len(node.prefix) {
+			return 0
+		}
+
+		prefix = prefix[common:]
+		node = node.findChild(prefix[0])
+
+		if node == nil {
+			return 0
+		}
+
+		prefix = prefix[1:]
+	}
+}
+
+func (self *artTrie) Len() int {
+	return self.root.size
+}
+
+func (self *artTrie) weigh(key []byte) float64 {
+	if node := self.find(key); node != nil {
+		return node.weight
+	}
+
+	return 0
+}
+
+func (self *artNode) expand(visit func(child topNode, label []byte, maxWeight float64)) (interface{}, float64, bool) {
+	for pos := self.lowerBound(0); pos >= 0; pos = self.after(pos) {
+		b, child := self.childAt(pos)
+		label := make([]byte, 0, 1+len(child.prefix))
+		visit(child, append(append(label, b), child.prefix...), child.maxWeight)
+	}
+
+	return self.value, self.weight, self.hasValue
+}
+
+func (self *artTrie) topK(prefix []byte, k int) []Completion {
+	return bestFirst(self.root, append([]byte{}, self.root.prefix...), prefix, k)
+}
+
+func (self *artTrie) Iterator() Iterator {
+	return newARTIterator(self.root, false)
+}
+
+func (self *artTrie) ReverseIterator() Iterator {
+	return newARTIterator(self.root, true)
+}
+
+type artIterator struct {
+	root    *artNode
+	reverse bool
+
+	// The path down to the current node, and the position of each node within
+	// its parent.
+	path      []*artNode
+	positions []int
+	key       []byte
+}
+
+func newARTIterator(root *artNode, reverse bool) *artIterator {
+	it := &artIterator{root: root, reverse: reverse}
+	it.reset()
+
+	if reverse {
+		it.last()
+	}
+
+	it.settle()
+	return it
+}
+
+func (self *artIterator) reset() {
+	self.path = append(self.path[:0], self.root)
+	self.positions = append(self.positions[:0], -1)
+	self.key = append(self.key[:0], self.root.prefix...)
+}
+
+func (self *artIterator) current() *artNode {
+	return self.path[len(self.path)-1]
+}
+
+func (self *artIterator) descend(pos int) {
+	b, child := self.current().childAt(pos)
+	self.path = append(self.path, child)
+	self.positions = append(self.positions, pos)
+	self.key = append(append(self.key, b), child.prefix...)
+}
+
+func (self *artIterator) pop() int {
+	node := self.current()
+	pos := self.positions[len(self.positions)-1]
+
+	self.path = self.path[:len(self.path)-1]
+	self.positions = self.positions[:len(self.positions)-1]
+	self.key = self.key[:len(self.key)-len(node.prefix)-1]
+
+	return pos
+}
+
+func (self *artIterator) skip() {
+	for len(self.path) > 1 {
+		pos := self.pop()
+
+		if next := self.current().after(pos); next >= 0 {
+			self.descend(next)
+			return
+		}
+	}
+
+	self.path = self.path[:0]
+}
+
+func (self *artIterator) advance() {
+	if self.current().count > 0 {
+		self.descend(self.current().lowerBound(0))
+	} else {
+		self.skip()
+	}
+}
+
+func (self *artIterator) last() {
+	for self.current().count > 0 {
+		self.descend(self.current().upperBound(255))
+	}
+}
+
+func (self *artIterator) retreat() {
+	if len(self.path) < 2 {
+		self.path = self.path[:0]
+		return
+	}
+
+	pos := self.pop()
+
+	if prev := self.current().before(pos); prev >= 0 {
+		self.descend(prev)
+		self.last()
+	}
+}
+
+func (self *artIterator) settle() {
+	for self.Valid() && !self.current().hasValue {
+		if self.reverse {
+			self.retreat()
+		} else {
+			self.advance()
+		}
+	}
+}
+
+func (self *artIterator) Seek(key []byte) {
+	self.reset()
+
+	// The root never has a prefix, so we can go straight to the children.
+	for len(key) > 0 {
+		node := self.current()
+		b := key[0]
+		var pos int
+
+		if self.reverse {
+			// Everything underneath comes after the key, so it's this node
+			// or whatever came before it.
+			if pos = node.upperBound(int(b)); pos < 0 {
+				self.settle()
+				return
+			}
+		} else {
+			// Everything underneath this node comes before the key.
+			if pos = node.lowerBound(int(b)); pos < 0 {
+				self.skip()
+				self.settle()
+				return
+			}
+		}
+
+		self.descend(pos)
+		cb, child := node.childAt(pos)
+		cmp := int(cb) - int(b)
+		rest := key[1:]
+
+		if cmp == 0 {
+			n := minOf(len(child.prefix), len(rest))
+			cmp = bytes.Compare(child.prefix[:n], rest[:n])
+
+			// The prefix runs past the end of the key, so this subtree
+			// comes after it.
+			if cmp == 0 && len(child.prefix) > len(rest) {
+				cmp = 1
+			}
+		}
+
+		if cmp < 0 {
+			// The whole subtree comes before the key.
+			if self.reverse {
+				self.last()
+			} else {
+				self.skip()
+			}
+
+			self.settle()
+			return
+		}
+
+		if cmp > 0 {
+			// The whole subtree comes after the key.
+			if self.reverse {
+				self.retreat()
+			}
+
+			self.settle()
+			return
+		}
+
+		key = rest[len(child.prefix):]
+	}
+
+	self.settle()
+}
+
+func (self *artIterator) Next() {
+	if !self.Valid() {
+		return
+	}
+
+	if self.reverse {
+		self.retreat()
+	} else {
+		self.advance()
+	}
+
+	self.settle()
+}
+
+func (self *artIterator) Valid() bool {
+	return len(self.path) > 0
+}
+
+func (self *artIterator) Key() []byte {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.key
+}
+
+func (self *artIterator) Value() interface{} {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.current().value
+}
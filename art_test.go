@@ -0,0 +1,144 @@
+package trie
+
+import (
+	"testing"
+)
+
+func TestARTSuite(t *testing.T) {
+	runSuite(t, NewART)
+}
+
+func TestARTMatchesTrie(t *testing.T) {
+	alphabet := make([]byte, 0, 80)
+
+	for b := '0'; len(alphabet) < cap(alphabet); b++ {
+		alphabet = append(alphabet, byte(b))
+	}
+
+	testMatchesTrie(t, NewART(), string(alphabet))
+}
+
+func artRoot(trie Trie) *artNode {
+	return trie.(*ordered).store.(*artTrie).root
+}
+
+func TestARTNodesGrowAndShrink(t *testing.T) {
+	trie := New(WithART())
+	grown := map[int]uint8{4: node4, 5: node16, 16: node16, 17: node48, 48: node48, 49: node256}
+
+	for i := 0; i < 256; i++ {
+		trie.Insert([]byte{'k', byte(i)}, i)
+		node := artRoot(trie).findChild('k')
+
+		if kind, ok := grown[node.count]; ok && node.kind != kind {
+			t.Fatalf(`Expected node with %d children to be kind %d, was %d.`, node.count, kind, node.kind)
+		}
+	}
+
+	root := artRoot(trie)
+
+	// Everything shares the "k", so the root only needs the one child.
+	if root.kind != node4 || root.count != 1 {
+		t.Fatalf(`Expected the root to be a node4 with a single child.`)
+	}
+
+	// Nodes hang on to their size for a little while on the way back down.
+	shrunk := map[int]uint8{37: node256, 36: node48, 12: node48, 11: node16, 3: node16, 2: node4}
+
+	for i := 255; i >= 0; i-- {
+		trie.Delete([]byte{'k', byte(i)})
+
+		if i < 2 {
+			continue
+		}
+
+		node := root.findChild('k')
+
+		if kind, ok := shrunk[node.count]; ok && node.kind != kind {
+			t.Fatalf(`Expected node with %d children to be kind %d, was %d.`, node.count, kind, node.kind)
+		}
+
+		if trie.Lookup([]byte{'k', byte(i - 1)}) != i-1 {
+			t.Fatalf(`Expected "k%d" to still be there.`, i-1)
+		}
+	}
+
+	if trie.Len() != 0 || root.count != 0 {
+		t.Fatalf(`Expected the trie to be empty.`)
+	}
+}
+
+func TestARTCompressesPaths(t *testing.T) {
+	trie := NewART()
+	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
+	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
+	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
+
+	node := artRoot(trie).findChild('p')
+
+	if string(node.prefix) != "refix1:prefix2" {
+		t.Fatalf(`Expected prefix to be "refix1:prefix2", got "%s".`, node.prefix)
+	}
+
+	trie.Delete([]byte("prefix1:prefix200:2015-05-01"))
+	node = artRoot(trie).findChild('p')
+
+	if string(node.prefix) != "refix1:prefix2:2015-05-" {
+		t.Fatalf(`Expected prefix to be "refix1:prefix2:2015-05-", got "%s".`, node.prefix)
+	}
+
+	if c := trie.DeletePrefix([]byte("prefix1:prefix2:2015-05-3")); c != 1 {
+		t.Fatalf(`Expected to remove 1 key, removed %d.`, c)
+	}
+
+	if trie.Lookup([]byte("prefix1:prefix2:2015-05-01")) != "Hello" {
+		t.Fatalf(`Expected "prefix1:prefix2:2015-05-01" to be "Hello".`)
+	}
+}
+
+func BenchmarkARTLookup(b *testing.B) {
+	trie := NewART()
+	keys := generateKeys(6, "")
+
+	for i, k := range keys {
+		trie.Insert([]byte(k), i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		trie.Lookup([]byte("abcdefa"))
+	}
+}
+
+func BenchmarkARTLookupBinary(b *testing.B) {
+	trie := NewART()
+	key := make([]byte, 3)
+
+	for i := 0; i < 1<<16; i++ {
+		trie.Insert([]byte{byte(i >> 8), byte(i), byte(i * 7)}, i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		key[0], key[1], key[2] = byte(i>>8), byte(i), byte(i*7)
+		trie.Lookup(key)
+	}
+}
+
+func BenchmarkTrieLookupBinary(b *testing.B) {
+	trie := New()
+	key := make([]byte, 3)
+
+	for i := 0; i < 1<<16; i++ {
+		trie.Insert([]byte{byte(i >> 8), byte(i), byte(i * 7)}, i)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		key[0], key[1], key[2] = byte(i>>8), byte(i), byte(i*7)
+		trie.Lookup(key)
+	}
+}
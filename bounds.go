@@ -0,0 +1,120 @@
+package trie
+
+import (
+	"bytes"
+)
+
+// BoundKind says how a Bound treats its key.
+type BoundKind int
+
+const (
+	// Unbounded goes all the way to the beginning or end of the trie.
+	Unbounded BoundKind = iota
+
+	// Inclusive includes the key itself.
+	Inclusive
+
+	// Exclusive stops just short of the key.
+	Exclusive
+)
+
+// A Bound is one end of a range. The zero Bound is Unbounded.
+type Bound struct {
+	Key  []byte
+	Kind BoundKind
+}
+
+// InclusiveBound returns a Bound that includes key.
+func InclusiveBound(key []byte) Bound {
+	return Bound{Key: key, Kind: Inclusive}
+}
+
+// ExclusiveBound returns a Bound that stops just short of key.
+func ExclusiveBound(key []byte) Bound {
+	return Bound{Key: key, Kind: Exclusive}
+}
+
+// Bounds are the two ends of a range. For everything from "a" on, say:
+//
+//	trie.Bounds{Start: trie.InclusiveBound([]byte("a"))}
+type Bounds struct {
+	Start, End Bound
+}
+
+// Between returns the Bounds from start to end, inclusive, which is what Range
+// and friends use.
+func Between(start, end []byte) Bounds {
+	return Bounds{InclusiveBound(start), InclusiveBound(end)}
+}
+
+// afterStart reports whether key is far enough along to be in the range.
+func (self Bounds) afterStart(key []byte) bool {
+	switch self.Start.Kind {
+	case Inclusive:
+		return bytes.Compare(key, self.Start.Key) >= 0
+	case Exclusive:
+		return bytes.Compare(key, self.Start.Key) > 0
+	}
+
+	return true
+}
+
+// beforeEnd reports whether key is early enough to be in the range.
+func (self Bounds) beforeEnd(key []byte) bool {
+	switch self.End.Kind {
+	case Inclusive:
+		return bytes.Compare(key, self.End.Key) <= 0
+	case Exclusive:
+		return bytes.Compare(key, self.End.Key) < 0
+	}
+
+	return true
+}
+
+// seekBound puts it on the first key it would visit that's inside b, going
+// whichever way it goes. Fresh iterators are already at the first key, so
+// there's nothing to do for Unbounded.
+func seekBound(it Iterator, b Bound) {
+	if b.Kind == Unbounded {
+		return
+	}
+
+	it.Seek(b.Key)
+
+	if b.Kind == Exclusive && it.Valid() && bytes.Equal(it.Key(), b.Key) {
+		it.Next()
+	}
+}
+
+// boundedRange walks the keys in bounds forwards, the same way for every
+// backend.
+func boundedRange(it Iterator, offset []byte, bounds Bounds, emit emitter, n int) {
+	for seekBound(it, bounds.Start); it.Valid() && n != 0; it.Next() {
+		if !bounds.beforeEnd(it.Key()) {
+			break
+		}
+
+		if isOffsetLesser(offset, it.Key()) {
+			emit(it.Key(), it.Value())
+
+			if n > 0 {
+				n -= 1
+			}
+		}
+	}
+}
+
+// boundedReverseRange walks the keys in bounds backwards.
+func boundedReverseRange(it Iterator, bounds Bounds, emit emitter, n int) {
+	for seekBound(it, bounds.End); it.Valid() && n != 0; it.Next() {
+		if !bounds.afterStart(it.Key()) {
+			break
+		}
+
+		emit(it.Key(), it.Value())
+
+		if n > 0 {
+			n -= 1
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package trie
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBoundedRange(t *testing.T) {
+	trie := setupTrie()
+
+	vals := trie.BoundedRangeN([]byte{}, Bounds{ExclusiveBound([]byte("20140903")), ExclusiveBound([]byte("20140906"))}, -1)
+
+	if len(vals) != 2 || vals["20140904"] == nil || vals["20140905"] == nil {
+		t.Fatalf(`Expected only "20140904" and "20140905", got %v.`, vals)
+	}
+
+	kvs := trie.BoundedRangeKV([]byte{}, Bounds{Start: InclusiveBound([]byte("20140910"))}, -1)
+
+	if keys := kvKeys(kvs); keys != "20140910,20140911" {
+		t.Fatalf(`Expected everything from "20140910" on, got %s.`, keys)
+	}
+
+	kvs = trie.BoundedRangeKV([]byte{}, Bounds{End: ExclusiveBound([]byte("20140903"))}, -1)
+
+	if keys := kvKeys(kvs); keys != "20140901,20140902" {
+		t.Fatalf(`Expected everything before "20140903", got %s.`, keys)
+	}
+
+	if vals := trie.BoundedRangeN([]byte{}, Bounds{}, -1); len(vals) != trie.Len() {
+		t.Fatalf(`Expected all %d keys, got %d.`, trie.Len(), len(vals))
+	}
+
+	if vals := trie.BoundedRangeN([]byte{}, Bounds{ExclusiveBound([]byte("20140903")), ExclusiveBound([]byte("20140903"))}, -1); len(vals) != 0 {
+		t.Fatalf(`Expected nothing, got %v.`, vals)
+	}
+}
+
+func TestReverseBoundedRange(t *testing.T) {
+	trie := setupTrie()
+	kvs := trie.ReverseBoundedRangeKV(Bounds{Start: ExclusiveBound([]byte("20140909"))}, -1)
+
+	if keys := kvKeys(kvs); keys != "20140911,20140910" {
+		t.Fatalf(`Expected everything after "20140909", last first, got %s.`, keys)
+	}
+
+	kvs = trie.ReverseBoundedRangeKV(Bounds{End: ExclusiveBound([]byte("20140905"))}, 2)
+
+	if keys := kvKeys(kvs); keys != "20140904,20140903" {
+		t.Fatalf(`Expected the last 2 keys before "20140905", got %s.`, keys)
+	}
+}
+
+func TestBoundedRangePage(t *testing.T) {
+	trie := setupTrie()
+	bounds := Bounds{Start: ExclusiveBound([]byte("20140907"))}
+
+	first, _ := trie.BoundedRangePage(bounds, "", 2)
+
+	if keys := kvKeys(first.Items); keys != "20140908,20140909" {
+		t.Fatalf(`Expected the first 2 keys after "20140907", got %s.`, keys)
+	}
+
+	second, _ := trie.BoundedRangePage(bounds, first.Cursor, 2)
+
+	if keys := kvKeys(second.Items); keys != "20140910,20140911" || second.Cursor != "" {
+		t.Fatalf(`Expected the last 2 keys and no cursor, got %s and %q.`, keys, second.Cursor)
+	}
+}
+
+func randomBound(rnd *rand.Rand) Bound {
+	return Bound{Key: randomBinaryKey(rnd), Kind: BoundKind(rnd.Intn(3))}
+}
+
+// Every backend should agree with checking the bounds against every key.
+func TestBoundedRangeMatchesBounds(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		for i := 0; i < 200; i++ {
+			trie.Insert(randomBinaryKey(rnd), i)
+		}
+
+		for i := 0; i < 300; i++ {
+			bounds := Bounds{randomBound(rnd), randomBound(rnd)}
+			n := rnd.Intn(10) - 1
+			expected := make([]KV, 0)
+
+			for it := trie.Iterator(); it.Valid(); it.Next() {
+				if bounds.afterStart(it.Key()) && bounds.beforeEnd(it.Key()) {
+					expected = append(expected, KV{append([]byte{}, it.Key()...), it.Value()})
+				}
+			}
+
+			forwards := expected
+
+			if n >= 0 && n < len(expected) {
+				forwards = expected[:n]
+			}
+
+			if got := trie.BoundedRangeKV([]byte{}, bounds, n); kvKeys(got) != kvKeys(forwards) {
+				t.Fatalf(`Expected %v to find %q, got %q.`, bounds, kvKeys(forwards), kvKeys(got))
+			}
+
+			if got := trie.BoundedRangeN([]byte{}, bounds, n); len(got) != len(forwards) {
+				t.Fatalf(`Expected %v to find %d keys, got %d.`, bounds, len(forwards), len(got))
+			}
+
+			got := trie.ReverseBoundedRangeKV(bounds, n)
+
+			for j, kv := range got {
+				if !bytes.Equal(kv.Key, expected[len(expected)-1-j].Key) {
+					t.Fatalf(`Expected %v backwards to find %q, got %q.`, bounds, expected[len(expected)-1-j].Key, kv.Key)
+				}
+			}
+
+			if len(got) != len(forwards) {
+				t.Fatalf(`Expected %v backwards to find %d keys, got %d.`, bounds, len(forwards), len(got))
+			}
+
+			page, _ := trie.BoundedRangePage(bounds, "", n)
+
+			if kvKeys(page.Items) != kvKeys(forwards) {
+				t.Fatalf(`Expected a page of %v to be %q, got %q.`, bounds, kvKeys(forwards), kvKeys(page.Items))
+			}
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package trie
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// A Codec turns values into bytes and back again, so that a Trie can be saved
+// with WriteTo and loaded up again with ReadFrom. Pick one with WithCodec.
+type Codec interface {
+	// Name identifies the codec in saved tries, so that we notice when
+	// something gets loaded with a different codec than it was saved with.
+	Name() string
+
+	Marshal(val interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// GobCodec encodes values with encoding/gob. This is the default. Anything
+// other than the basic types has to be registered with gob.Register first.
+type GobCodec struct{}
+
+// gob won't encode a bare interface value, so we wrap it up in something it
+// will.
+type gobValue struct {
+	V interface{}
+}
+
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+func (GobCodec) Marshal(val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(gobValue{val}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte) (interface{}, error) {
+	var val gobValue
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&val); err != nil {
+		return nil, err
+	}
+
+	return val.V, nil
+}
+
+// JSONCodec encodes values as JSON. They come back the way encoding/json
+// decodes into an interface{}, so numbers turn into float64s, structs into
+// maps, and so on.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+func (JSONCodec) Marshal(val interface{}) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (JSONCodec) Unmarshal(data []byte) (interface{}, error) {
+	var val interface{}
+
+	if err := json.Unmarshal(data, &val); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// BytesCodec stores values as they are. Every value has to be a []byte or a
+// string, and they all come back as []byte.
+type BytesCodec struct{}
+
+func (BytesCodec) Name() string {
+	return "bytes"
+}
+
+func (BytesCodec) Marshal(val interface{}) ([]byte, error) {
+	switch v := val.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+
+	return nil, fmt.Errorf("trie: BytesCodec can't encode a %T", val)
+}
+
+func (BytesCodec) Unmarshal(data []byte) (interface{}, error) {
+	return data, nil
+}
@@ -0,0 +1,69 @@
+package trie
+
+import (
+	"testing"
+)
+
+func TestGobCodec(t *testing.T) {
+	codec := GobCodec{}
+
+	for _, val := range []interface{}{"Hello", 42, 1.5, []byte("World"), nil} {
+		data, err := codec.Marshal(val)
+
+		if err != nil {
+			t.Fatalf(`Expected to marshal %v, got %v.`, val, err)
+		}
+
+		res, err := codec.Unmarshal(data)
+
+		if err != nil {
+			t.Fatalf(`Expected to unmarshal %v, got %v.`, val, err)
+		}
+
+		if b, ok := val.([]byte); ok {
+			if string(res.([]byte)) != string(b) {
+				t.Fatalf(`Expected %v, got %v.`, val, res)
+			}
+		} else if res != val {
+			t.Fatalf(`Expected %v, got %v.`, val, res)
+		}
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	codec := JSONCodec{}
+	data, err := codec.Marshal(map[string]interface{}{"count": 2})
+
+	if err != nil {
+		t.Fatalf(`Expected to marshal, got %v.`, err)
+	}
+
+	res, err := codec.Unmarshal(data)
+
+	if err != nil {
+		t.Fatalf(`Expected to unmarshal, got %v.`, err)
+	}
+
+	if res.(map[string]interface{})["count"] != 2.0 {
+		t.Fatalf(`Expected count to be 2, got %v.`, res)
+	}
+}
+
+func TestBytesCodec(t *testing.T) {
+	codec := BytesCodec{}
+	data, err := codec.Marshal("Hello")
+
+	if err != nil {
+		t.Fatalf(`Expected to marshal, got %v.`, err)
+	}
+
+	res, _ := codec.Unmarshal(data)
+
+	if string(res.([]byte)) != "Hello" {
+		t.Fatalf(`Expected "Hello", got %v.`, res)
+	}
+
+	if _, err := codec.Marshal(42); err == nil {
+		t.Fatalf(`Expected marshalling an int to fail.`)
+	}
+}
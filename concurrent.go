@@ -0,0 +1,509 @@
+package trie
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// A trie that's safe to use from lots of goroutines at once. Every node has its
+// own lock, and we use lock coupling (hand-over-hand locking) to get around:
+// the lock on a child is always taken before the lock on its parent is let go,
+// so nobody can pull a node out from under someone who is on their way down to
+// it. Readers take shared locks, so they only ever wait on a writer that's
+// working on the very node they want, and writers only hold on to the part of
+// the path they might have to change.
+type concurrentNode struct {
+	mu       sync.RWMutex
+	key      byte
+	value    interface{}
+	hasValue bool
+
+	// The weight of this node's key, and the biggest weight of any key in
+	// the subtree, so TopK knows which way to go.
+	weight    float64
+	maxWeight float64
+
+	// The number of keys stored in this subtree, including this node. It
+	// gets counted up and down once a write is done, without any locks, so
+	// it can be behind for a moment while writes are going on.
+	size atomic.Int64
+
+	// Sorted by key.
+	children []*concurrentNode
+}
+
+type concurrentTrie struct {
+	// The root never changes, even when the whole trie gets replaced, so
+	// that there's always something to lock.
+	root *concurrentNode
+
+	// Writes, and reads that look at more than one key, hold this shared.
+	// Snapshots and transactions hold it exclusively, so that nobody sees
+	// them half done. Reads of a single key don't need it at all.
+	commits sync.RWMutex
+}
+
+// WithConcurrent makes New return a trie that's safe to use from multiple
+// goroutines without any locking of your own. Reads can go on in parallel with
+// each other and with writes to other parts of the trie.
+//
+// Reads that look at more than one key, like Range, never see part of a
+// transaction, but they can see other writes that happen while they're
+// running. DeletePrefix and DeleteRange delete one key at a time. Iterators
+// never fail, but they might or might not see keys that are inserted or
+// deleted after they were created, so use a Snapshot if that matters.
+func WithConcurrent() Option {
+	return func(o *options) {
+		o.backend = func() store {
+			return &concurrentTrie{root: new(concurrentNode)}
+		}
+	}
+}
+
+// NewConcurrent returns an empty Trie that's safe for concurrent use. It's the
+// same as New(WithConcurrent()).
+func NewConcurrent() Trie {
+	return New(WithConcurrent())
+}
+
+// find returns the position of the first child with a key >= b. The caller has
+// to hold the node's lock.
+func (self *concurrentNode) find(b byte) int {
+	return sort.Search(len(self.children), func(i int) bool {
+		return self.children[i].key >= b
+	})
+}
+
+func (self *concurrentNode) child(b byte) *concurrentNode {
+	i := self.find(b)
+
+	if i < len(self.children) && self.children[i].key == b {
+		return self.children[i]
+	}
+
+	return nil
+}
+
+func (self *concurrentNode) empty() bool {
+	return !self.hasValue && len(self.children) == 0
+}
+
+// lookup returns the value and weight stored at key, along with whether or not
+// there's anything there.
+func (self *concurrentTrie) lookup(key []byte) (interface{}, float64, bool) {
+	node := self.root
+	node.mu.RLock()
+
+	for _, b := range key {
+		child := node.child(b)
+
+		if child == nil {
+			node.mu.RUnlock()
+			return nil, 0, false
+		}
+
+		child.mu.RLock()
+		node.mu.RUnlock()
+		node = child
+	}
+
+	val, weight, ok := node.value, node.weight, node.hasValue
+	node.mu.RUnlock()
+	return val, weight, ok
+}
+
+func (self *concurrentTrie) Get(key []byte) (interface{}, bool) {
+	val, _, ok := self.lookup(key)
+	return val, ok
+}
+
+func (self *concurrentTrie) weigh(key []byte) float64 {
+	_, weight, _ := self.lookup(key)
+	return weight
+}
+
+func (self *concurrentTrie) Insert(key []byte, val interface{}) {
+	self.commits.RLock()
+	defer self.commits.RUnlock()
+
+	self.insert(key, val, 0)
+}
+
+func (self *concurrentTrie) InsertWeighted(key []byte, val interface{}, weight float64) {
+	self.commits.RLock()
+	defer self.commits.RUnlock()
+
+	self.insert(key, val, weight)
+}
+
+func (self *concurrentTrie) insert(key []byte, val interface{}, weight float64) {
+	path := make([]*concurrentNode, 0, len(key))
+	node := self.root
+	node.mu.Lock()
+
+	for _, b := range key {
+		i := node.find(b)
+
+		if i == len(node.children) || node.children[i].key != b {
+			child := &concurrentNode{key: b, maxWeight: math.Inf(-1)}
+			node.children = append(node.children, nil)
+			copy(node.children[i+1:], node.children[i:])
+			node.children[i] = child
+		}
+
+		child := node.children[i]
+		child.mu.Lock()
+		node.mu.Unlock()
+		path = append(path, node)
+		node = child
+	}
+
+	added := !node.hasValue
+	node.value = val
+	node.hasValue = true
+	node.weight = weight
+	changed := node.reweigh()
+	node.mu.Unlock()
+
+	if added {
+		for _, n := range append(path, node) {
+			n.size.Add(1)
+		}
+	}
+
+	if changed {
+		reweighPath(path)
+	}
+}
+
+// reweigh works out the node's maxWeight again, returning whether or not it
+// changed. The caller has to hold the node's lock, and we take each child's in
+// turn to read theirs.
+func (self *concurrentNode) reweigh() bool {
+	best := math.Inf(-1)
+
+	if self.hasValue {
+		best = self.weight
+	}
+
+	for _, child := range self.children {
+		child.mu.RLock()
+
+		if child.maxWeight > best {
+			best = child.maxWeight
+		}
+
+		child.mu.RUnlock()
+	}
+
+	changed := best != self.maxWeight
+	self.maxWeight = best
+	return changed
+}
+
+// reweighPath fixes up the maxWeights on the way back up path once the node
+// below the end of it has changed. The locks on the way down are long gone by
+// then, so we take them again one at a time, from the bottom up. Whoever
+// changes a node's maxWeight always goes on to its parent afterwards, so once
+// the writes are done every node is right again, even if a few writers were
+// going up the same path at once. In the meantime TopK might not see a write
+// that's still in progress, which is no different to Range.
+//
+// As soon as a node comes out the same as it was, nothing above it is going to
+// change either, so most writes don't have to go far.
+func reweighPath(path []*concurrentNode) {
+	for i := len(path) - 1; i >= 0; i-- {
+		node := path[i]
+		node.mu.Lock()
+		changed := node.reweigh()
+		node.mu.Unlock()
+
+		if !changed {
+			return
+		}
+	}
+}
+
+func (self *concurrentTrie) Delete(key []byte) bool {
+	self.commits.RLock()
+	defer self.commits.RUnlock()
+
+	return self.delete(key)
+}
+
+// delete removes key and prunes any nodes that end up empty. On the way down
+// we keep hold of every node that could become empty, but as soon as we get to
+// one that's going to survive (it has a value of its own, or other children)
+// we know nothing above it will change, so we let go of everything above it.
+func (self *concurrentTrie) delete(key []byte) bool {
+	path := []*concurrentNode{self.root}
+	locked := []*concurrentNode{self.root}
+	self.root.mu.Lock()
+
+	unlock := func() {
+		for _, node := range locked {
+			node.mu.Unlock()
+		}
+	}
+
+	for i, b := range key {
+		child := locked[len(locked)-1].child(b)
+
+		if child == nil {
+			unlock()
+			return false
+		}
+
+		child.mu.Lock()
+
+		// The node for key itself only survives if it has children.
+		survives := len(child.children) > 1 || (child.hasValue && i < len(key)-1)
+		survives = survives || (len(child.children) > 0 && i == len(key)-1)
+
+		if survives {
+			unlock()
+			locked = locked[:0]
+		}
+
+		path = append(path, child)
+		locked = append(locked, child)
+	}
+
+	node := locked[len(locked)-1]
+
+	if !node.hasValue {
+		unlock()
+		return false
+	}
+
+	node.value = nil
+	node.hasValue = false
+	node.weight = 0
+
+	for i := len(locked) - 1; i > 0 && locked[i].empty(); i-- {
+		parent := locked[i-1]
+		j := parent.find(locked[i].key)
+		parent.children = append(parent.children[:j], parent.children[j+1:]...)
+	}
+
+	unlock()
+
+	// Nodes that got pruned come out with nothing in them, which is fine,
+	// since nobody can get to them anymore.
+	for _, n := range path {
+		n.size.Add(-1)
+	}
+
+	reweighPath(path)
+	return true
+}
+
+func (self *concurrentTrie) Len() int {
+	return int(self.root.size.Load())
+}
+
+// swap takes over the contents of other, which nobody else can be using.
+func (self *concurrentTrie) swap(other store) {
+	fresh := other.(*concurrentTrie)
+
+	self.root.mu.Lock()
+	self.root.value = fresh.root.value
+	self.root.hasValue = fresh.root.hasValue
+	self.root.weight = fresh.root.weight
+	self.root.maxWeight = fresh.root.maxWeight
+	self.root.children = fresh.root.children
+	self.root.size.Store(fresh.root.size.Load())
+	self.root.mu.Unlock()
+}
+
+// snapshot copies the whole trie, holding off writes while it does.
+func (self *concurrentTrie) snapshot() store {
+	self.commits.Lock()
+	defer self.commits.Unlock()
+
+	fresh := &concurrentTrie{root: new(concurrentNode)}
+
+	for it := self.Iterator(); it.Valid(); it.Next() {
+		fresh.insert(it.Key(), it.Value(), self.weigh(it.Key()))
+	}
+
+	return fresh
+}
+
+func (self *concurrentTrie) commit(txn *Txn) {
+	self.commits.Lock()
+	defer self.commits.Unlock()
+
+	txn.each(func(key []byte, val interface{}, weight float64, deleted bool) {
+		if deleted {
+			self.delete(key)
+		} else {
+			self.insert(key, val, weight)
+		}
+	})
+}
+
+func (self *concurrentTrie) hold() func() {
+	self.commits.RLock()
+	return self.commits.RUnlock
+}
+
+func (self *concurrentNode) navValue() (interface{}, bool) {
+	return self.value, self.hasValue
+}
+
+func (self *concurrentNode) navLen() int {
+	return len(self.children)
+}
+
+func (self *concurrentNode) navSearch(b byte) int {
+	return self.find(b)
+}
+
+// Going down takes the child's lock, so navigation is lock coupled like
+// everything else. The caller has to hold the node's own lock.
+func (self *concurrentNode) navEnter(i int) (navNode, byte) {
+	child := self.children[i]
+	child.mu.RLock()
+	return child, child.key
+}
+
+func (self *concurrentNode) navLeave() {
+	self.mu.RUnlock()
+}
+
+func (self *concurrentTrie) ceiling(key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	self.root.mu.RLock()
+	defer self.root.mu.RUnlock()
+	return navCeiling(self.root, []byte{}, key, inclusive)
+}
+
+func (self *concurrentTrie) floor(key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	self.root.mu.RLock()
+	defer self.root.mu.RUnlock()
+	return navFloor(self.root, []byte{}, key, inclusive)
+}
+
+func (self *concurrentTrie) last() ([]byte, interface{}, bool) {
+	self.root.mu.RLock()
+	defer self.root.mu.RUnlock()
+	return navLast(self.root, []byte{})
+}
+
+// Unlike navigation, TopK lets go of a node before it opens up the children,
+// so it can see writes that happen part way through, the same as Range can.
+func (self *concurrentNode) expand(visit func(child topNode, label []byte, maxWeight float64)) (interface{}, float64, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	for _, child := range self.children {
+		child.mu.RLock()
+		maxWeight, empty := child.maxWeight, child.empty()
+		child.mu.RUnlock()
+
+		if !empty {
+			visit(child, []byte{child.key}, maxWeight)
+		}
+	}
+
+	return self.value, self.weight, self.hasValue
+}
+
+func (self *concurrentTrie) topK(prefix []byte, k int) []Completion {
+	defer self.hold()()
+	return bestFirst(self.root, []byte{}, prefix, k)
+}
+
+// rank adds up the sizes to the left of the path down to key, the same as
+// trieImpl does, locking its way down like Get.
+func (self *concurrentTrie) rank(key []byte) int {
+	defer self.hold()()
+
+	count := 0
+	node := self.root
+	node.mu.RLock()
+
+	for _, b := range key {
+		// This node is a prefix of the key, so it comes before it.
+		if node.hasValue {
+			count += 1
+		}
+
+		i := node.find(b)
+
+		for _, child := range node.children[:i] {
+			count += int(child.size.Load())
+		}
+
+		if i == len(node.children) || node.children[i].key != b {
+			break
+		}
+
+		child := node.children[i]
+		child.mu.RLock()
+		node.mu.RUnlock()
+		node = child
+	}
+
+	node.mu.RUnlock()
+	return count
+}
+
+func (self *concurrentTrie) selectAt(i int) ([]byte, interface{}, bool) {
+	defer self.hold()()
+
+	if i < 0 {
+		return nil, nil, false
+	}
+
+	key := make([]byte, 0)
+	node := self.root
+	node.mu.RLock()
+
+	for {
+		if node.hasValue {
+			if i == 0 {
+				val := node.value
+				node.mu.RUnlock()
+				return key, val, true
+			}
+
+			i -= 1
+		}
+
+		var next *concurrentNode
+
+		for _, child := range node.children {
+			size := int(child.size.Load())
+
+			if i < size {
+				next = child
+				break
+			}
+
+			i -= size
+		}
+
+		// Either i is past the end, or a write is still counting its way
+		// up and the sizes don't add up just yet.
+		if next == nil {
+			node.mu.RUnlock()
+			return nil, nil, false
+		}
+
+		next.mu.RLock()
+		node.mu.RUnlock()
+		key = append(key, next.key)
+		node = next
+	}
+}
+
+func (self *concurrentTrie) Iterator() Iterator {
+	return newSeekIterator(self, false)
+}
+
+func (self *concurrentTrie) ReverseIterator() Iterator {
+	return newSeekIterator(self, true)
+}
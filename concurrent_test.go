@@ -0,0 +1,179 @@
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSuite(t *testing.T) {
+	runSuite(t, NewConcurrent)
+}
+
+func TestConcurrentMatchesTrie(t *testing.T) {
+	testMatchesTrie(t, NewConcurrent(), "abc")
+}
+
+func TestConcurrentDeletePrunes(t *testing.T) {
+	trie := NewConcurrent()
+	trie.Insert([]byte("abc"), 1)
+	trie.Insert([]byte("abd"), 2)
+	trie.Delete([]byte("abc"))
+	trie.Delete([]byte("abd"))
+
+	root := trie.(*ordered).store.(*concurrentTrie).root
+
+	if len(root.children) != 0 {
+		t.Fatalf(`Expected the root to have no children left, got %d.`, len(root.children))
+	}
+}
+
+// Run this one with -race. Every goroutine works on keys of its own, plus a
+// handful of keys that everyone fights over, while readers check that
+// everything they see makes sense.
+func TestConcurrentHammer(t *testing.T) {
+	trie := NewConcurrent()
+	writers, readers, rounds := 8, 8, 500
+
+	if testing.Short() {
+		rounds = 100
+	}
+
+	// Every value is its own key, so readers can check they never see a
+	// value under the wrong key.
+	check := func(res map[string]interface{}) error {
+		for k, v := range res {
+			if v != k {
+				return fmt.Errorf(`Expected %q to have value %q, got %v.`, k, k, v)
+			}
+		}
+
+		return nil
+	}
+
+	errs := make(chan error, writers+readers)
+	owned := make([]map[string]bool, writers)
+	done := make(chan struct{})
+	var wg, rg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		owned[w] = make(map[string]bool)
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+
+			for i := 0; i < rounds; i++ {
+				key := fmt.Sprintf("w%d:%03d", w, rnd.Intn(100))
+				shared := fmt.Sprintf("shared:%d", rnd.Intn(4))
+
+				if rnd.Intn(3) == 0 {
+					trie.Delete([]byte(key))
+					delete(owned[w], key)
+					trie.Delete([]byte(shared))
+				} else {
+					trie.Insert([]byte(key), key)
+					owned[w][key] = true
+					trie.Insert([]byte(shared), shared)
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		rg.Add(1)
+
+		go func(r int) {
+			defer rg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				prefix := []byte(fmt.Sprintf("w%d", r%writers))
+
+				if err := check(trie.Prefix(prefix)); err != nil {
+					errs <- err
+					return
+				}
+
+				if err := check(trie.Range([]byte("shared:0"), []byte("w3"))); err != nil {
+					errs <- err
+					return
+				}
+
+				// The sizes can be behind while writes are going on, but
+				// whatever Select finds should still be a real key.
+				if key, val, ok := trie.Select(trie.Len() / 2); ok && val != string(key) {
+					errs <- fmt.Errorf(`Expected %q to have value %q, got %v.`, key, key, val)
+					return
+				}
+
+				var prev []byte
+
+				for it := trie.Iterator(); it.Valid(); it.Next() {
+					if prev != nil && bytes.Compare(prev, it.Key()) >= 0 {
+						errs <- fmt.Errorf(`Expected %q to come after %q.`, it.Key(), prev)
+						return
+					}
+
+					prev = append(prev[:0], it.Key()...)
+				}
+			}
+		}(r)
+	}
+
+	wg.Wait()
+	close(done)
+	rg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	expected := 0
+
+	for w := 0; w < writers; w++ {
+		prefix := []byte(fmt.Sprintf("w%d:", w))
+
+		if c := trie.CountPrefix(prefix); c != len(owned[w]) {
+			t.Fatalf(`Expected %d keys under %q, got %d.`, len(owned[w]), prefix, c)
+		}
+
+		for key := range owned[w] {
+			if trie.Lookup([]byte(key)) != key {
+				t.Fatalf(`Expected to find %q.`, key)
+			}
+		}
+
+		expected += len(owned[w])
+	}
+
+	expected += trie.CountPrefix([]byte("shared:"))
+
+	if trie.Len() != expected {
+		t.Fatalf(`Expected a length of %d, got %d.`, expected, trie.Len())
+	}
+
+	// Once the writes are done, the sizes should all add up again.
+	i := 0
+
+	for it := trie.Iterator(); it.Valid(); it.Next() {
+		if r := trie.Rank(it.Key()); r != i {
+			t.Fatalf(`Expected rank of %q to be %d, got %d.`, it.Key(), i, r)
+		}
+
+		if key, _, _ := trie.Select(i); !bytes.Equal(key, it.Key()) {
+			t.Fatalf(`Expected key %d to be %q, got %q.`, i, it.Key(), key)
+		}
+
+		i += 1
+	}
+}
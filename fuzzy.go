@@ -0,0 +1,111 @@
+package trie
+
+import (
+	"sort"
+)
+
+// A FuzzyMatch is a key that Fuzzy found, along with how many single byte
+// insertions, deletions or substitutions it is away from the query.
+type FuzzyMatch struct {
+	Key      []byte
+	Value    interface{}
+	Distance int
+}
+
+// levenshtein works out the edit distance between the query and the key we're
+// walking down, one row of the usual dynamic programming table per byte of the
+// key. The smallest number in a row is the best any longer key could do, so
+// once that's past max we can stop.
+type levenshtein struct {
+	query []byte
+	max   int
+
+	// rows[i] is the row for the first i bytes of the key. We keep the rows
+	// we've popped off around to reuse them.
+	rows  [][]int
+	depth int
+}
+
+func newLevenshtein(query []byte, max int) *levenshtein {
+	row := make([]int, len(query)+1)
+
+	for i := range row {
+		row[i] = i
+	}
+
+	return &levenshtein{query: query, max: max, rows: [][]int{row}}
+}
+
+func (self *levenshtein) push(b byte) bool {
+	self.depth += 1
+
+	if self.depth == len(self.rows) {
+		self.rows = append(self.rows, make([]int, len(self.query)+1))
+	}
+
+	prev, row := self.rows[self.depth-1], self.rows[self.depth]
+	row[0] = prev[0] + 1
+	best := row[0]
+
+	for i := 1; i < len(row); i++ {
+		cost := 1
+
+		if self.query[i-1] == b {
+			cost = 0
+		}
+
+		row[i] = minOf(minOf(prev[i]+1, row[i-1]+1), prev[i-1]+cost)
+		best = minOf(best, row[i])
+	}
+
+	return best <= self.max
+}
+
+func (self *levenshtein) pop() {
+	self.depth -= 1
+}
+
+func (self *levenshtein) distance() int {
+	return self.rows[self.depth][len(self.query)]
+}
+
+func (self *levenshtein) matches() bool {
+	return self.distance() <= self.max
+}
+
+// fuzzy finds everything within maxDist of query, closest first. If n isn't
+// negative, only the n closest come back.
+func fuzzy(s searcher, query []byte, maxDist, n int) []FuzzyMatch {
+	res := make([]FuzzyMatch, 0)
+
+	if maxDist < 0 || n == 0 {
+		return res
+	}
+
+	m := newLevenshtein(query, maxDist)
+
+	s.search(m, func(key []byte, val interface{}) bool {
+		res = append(res, FuzzyMatch{append([]byte{}, key...), val, m.distance()})
+		return true
+	})
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Distance < res[j].Distance
+	})
+
+	if n > 0 && n < len(res) {
+		res = res[:n]
+	}
+
+	return res
+}
+
+// Fuzzy returns the keys that are within maxDist edits of query, closest first
+// and in order after that. If n isn't negative, only the n closest come back.
+func (self *trieImpl) Fuzzy(query []byte, maxDist, n int) []FuzzyMatch {
+	return fuzzy(self, query, maxDist, n)
+}
+
+func (self *ordered) Fuzzy(query []byte, maxDist, n int) []FuzzyMatch {
+	return fuzzy(self, query, maxDist, n)
+}
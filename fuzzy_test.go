@@ -0,0 +1,139 @@
+package trie
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFuzzy(t *testing.T) {
+	trie := New()
+
+	for _, word := range []string{"hello", "help", "hell", "yellow", "hallo", "world", "held"} {
+		trie.Insert([]byte(word), word)
+	}
+
+	res := trie.Fuzzy([]byte("hello"), 1, -1)
+	expected := []string{"hello", "hallo", "hell"}
+
+	if len(res) != len(expected) {
+		t.Fatalf(`Expected %d matches, got %d.`, len(expected), len(res))
+	}
+
+	for i, key := range expected {
+		if string(res[i].Key) != key || res[i].Value != key {
+			t.Fatalf(`Expected match %d to be %q, got %q.`, i, key, res[i].Key)
+		}
+	}
+
+	if res[0].Distance != 0 || res[1].Distance != 1 {
+		t.Fatalf(`Expected distances 0 and 1, got %d and %d.`, res[0].Distance, res[1].Distance)
+	}
+
+	if res := trie.Fuzzy([]byte("hello"), 2, 4); len(res) != 4 || string(res[3].Key) != "held" {
+		t.Fatalf(`Expected the 4 closest matches, ending with "held", got %v.`, res)
+	}
+
+	if res := trie.Fuzzy([]byte("xyz"), 1, -1); len(res) != 0 {
+		t.Fatalf(`Expected no matches, got %d.`, len(res))
+	}
+}
+
+// countingMatcher keeps track of how many bytes get pushed into a matcher.
+type countingMatcher struct {
+	matcher
+	pushes int
+}
+
+func (self *countingMatcher) push(b byte) bool {
+	self.pushes += 1
+	return self.matcher.push(b)
+}
+
+func TestFuzzyPrunes(t *testing.T) {
+	trie := New().(*trieImpl)
+
+	for _, key := range generateKeys(4, "") {
+		trie.Insert([]byte(key), key)
+	}
+
+	m := &countingMatcher{matcher: newLevenshtein([]byte("abcde"), 1)}
+	trie.search(m, func(key []byte, val interface{}) bool {
+		return true
+	})
+
+	if nodes := countNodes(trie); m.pushes >= nodes/10 {
+		t.Fatalf(`Expected to look at a small part of the %d nodes, looked at %d.`, nodes, m.pushes)
+	}
+}
+
+func levenshteinDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			next := minOf(minOf(row[j]+1, row[j-1]+1), prev+cost)
+			prev = row[j]
+			row[j] = next
+		}
+	}
+
+	return row[len(b)]
+}
+
+func TestFuzzyMatchesBruteForce(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		keys := make([]string, 0)
+
+		for i := 0; i < 500; i++ {
+			key := make([]byte, rnd.Intn(7))
+
+			for j := range key {
+				key[j] = "abcd"[rnd.Intn(4)]
+			}
+
+			keys = append(keys, string(key))
+			trie.Insert(key, string(key))
+		}
+
+		for i := 0; i < 50; i++ {
+			query := keys[rnd.Intn(len(keys))] + "ab"[rnd.Intn(2):]
+			maxDist := rnd.Intn(3)
+			expected := 0
+
+			for it := trie.Iterator(); it.Valid(); it.Next() {
+				if levenshteinDistance(string(it.Key()), query) <= maxDist {
+					expected += 1
+				}
+			}
+
+			res := trie.Fuzzy([]byte(query), maxDist, -1)
+
+			if len(res) != expected {
+				t.Fatalf(`Expected %d matches for %q, got %d.`, expected, query, len(res))
+			}
+
+			for j, match := range res {
+				if d := levenshteinDistance(string(match.Key), query); d != match.Distance {
+					t.Fatalf(`Expected %q to be %d away from %q, got %d.`, match.Key, d, query, match.Distance)
+				}
+
+				if j > 0 && res[j-1].Distance > match.Distance {
+					t.Fatalf(`Expected matches to be closest first.`)
+				}
+			}
+		}
+	})
+}
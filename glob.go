@@ -0,0 +1,227 @@
+package trie
+
+import (
+	"errors"
+)
+
+// ErrBadPattern is returned by Match when the pattern can't be compiled, like
+// an unclosed or empty [] class, a backwards range or a trailing \.
+var ErrBadPattern = errors.New("trie: malformed pattern")
+
+// A glob is a compiled Match pattern. Everything other than * matches exactly
+// one byte out of a set, so a plain byte is just a set with one thing in it.
+type globToken struct {
+	star bool
+	set  [4]uint64
+}
+
+func (self *globToken) add(b byte) {
+	self.set[b/64] |= 1 << (b % 64)
+}
+
+func (self *globToken) has(b byte) bool {
+	return self.set[b/64]&(1<<(b%64)) != 0
+}
+
+func compileGlob(pattern []byte) ([]globToken, error) {
+	tokens := make([]globToken, 0, len(pattern))
+
+	for i := 0; i < len(pattern); i++ {
+		var tok globToken
+
+		switch pattern[i] {
+		case '*':
+			tok.star = true
+		case '?':
+			tok.set = [4]uint64{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, ErrBadPattern
+			}
+
+			i += 1
+			tok.add(pattern[i])
+		case '[':
+			n, err := compileClass(pattern[i+1:], &tok)
+
+			if err != nil {
+				return nil, err
+			}
+
+			i += n
+		default:
+			tok.add(pattern[i])
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens, nil
+}
+
+// compileClass reads a character class like [a-z] into tok, starting just
+// after the opening bracket. It returns how many bytes it used up, including
+// the closing bracket.
+func compileClass(pattern []byte, tok *globToken) (int, error) {
+	i := 0
+	negate := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+
+	if negate {
+		i += 1
+	}
+
+	// Reads one byte of the class, which might be escaped.
+	next := func() (byte, error) {
+		if i < len(pattern) && pattern[i] == '\\' {
+			i += 1
+		}
+
+		if i == len(pattern) {
+			return 0, ErrBadPattern
+		}
+
+		i += 1
+		return pattern[i-1], nil
+	}
+
+	for first := true; ; first = false {
+		if i == len(pattern) {
+			return 0, ErrBadPattern
+		}
+
+		if pattern[i] == ']' {
+			if first {
+				return 0, ErrBadPattern
+			}
+
+			break
+		}
+
+		lo, err := next()
+
+		if err != nil {
+			return 0, err
+		}
+
+		hi := lo
+
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			i += 1
+
+			if hi, err = next(); err != nil {
+				return 0, err
+			}
+
+			if hi < lo {
+				return 0, ErrBadPattern
+			}
+		}
+
+		for b := int(lo); b <= int(hi); b++ {
+			tok.add(byte(b))
+		}
+	}
+
+	if negate {
+		for j := range tok.set {
+			tok.set[j] = ^tok.set[j]
+		}
+	}
+
+	return i + 1, nil
+}
+
+// globMatcher runs the pattern as an NFA. states[d] says which tokens we could
+// be up to after d bytes of the key, and once that's empty there's nothing
+// left that could match.
+type globMatcher struct {
+	tokens []globToken
+	states [][]bool
+	depth  int
+}
+
+func newGlobMatcher(tokens []globToken) *globMatcher {
+	m := &globMatcher{tokens: tokens, states: [][]bool{make([]bool, len(tokens)+1)}}
+	m.states[0][0] = true
+	m.closure(m.states[0])
+	return m
+}
+
+// closure lets a * match nothing, so wherever we're up to a * we could just as
+// well be past it.
+func (self *globMatcher) closure(state []bool) {
+	for i, tok := range self.tokens {
+		if state[i] && tok.star {
+			state[i+1] = true
+		}
+	}
+}
+
+func (self *globMatcher) push(b byte) bool {
+	self.depth += 1
+
+	if self.depth == len(self.states) {
+		self.states = append(self.states, make([]bool, len(self.tokens)+1))
+	}
+
+	prev, state := self.states[self.depth-1], self.states[self.depth]
+	alive := false
+
+	for i := range state {
+		state[i] = false
+	}
+
+	for i, tok := range self.tokens {
+		if !prev[i] {
+			continue
+		}
+
+		if tok.star {
+			state[i] = true
+			alive = true
+		} else if tok.has(b) {
+			state[i+1] = true
+			alive = true
+		}
+	}
+
+	self.closure(state)
+	return alive
+}
+
+func (self *globMatcher) pop() {
+	self.depth -= 1
+}
+
+func (self *globMatcher) matches() bool {
+	return self.states[self.depth][len(self.tokens)]
+}
+
+func match(s searcher, pattern []byte) (map[string]interface{}, error) {
+	tokens, err := compileGlob(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]interface{})
+
+	s.search(newGlobMatcher(tokens), func(key []byte, val interface{}) bool {
+		res[string(key)] = val
+		return true
+	})
+
+	return res, nil
+}
+
+// Match returns every key that matches pattern, where ? matches any one byte, *
+// matches any number of bytes, and [a-z0-9] matches any one byte in the class
+// ([!a-z] or [^a-z] for any byte not in it). Anything can be escaped with a
+// backslash.
+func (self *trieImpl) Match(pattern []byte) (map[string]interface{}, error) {
+	return match(self, pattern)
+}
+
+func (self *ordered) Match(pattern []byte) (map[string]interface{}, error) {
+	return match(self, pattern)
+}
@@ -0,0 +1,154 @@
+package trie
+
+import (
+	"math/rand"
+	"path"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	trie := setupTrie()
+
+	vals, err := trie.Match([]byte("2014090?"))
+
+	if err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if len(vals) != 9 {
+		t.Fatalf(`Expected length of vals to be 9, got %d.`, len(vals))
+	}
+
+	if vals["20140906"] != "20140906" {
+		t.Fatalf(`Expected "20140906" to be "20140906", got %v.`, vals["20140906"])
+	}
+
+	if vals, _ := trie.Match([]byte("201409[1-9][!0]")); len(vals) != 1 || vals["20140911"] == nil {
+		t.Fatalf(`Expected only "20140911" to match, got %v.`, vals)
+	}
+
+	if vals, _ := trie.Match([]byte("*1")); len(vals) != 2 {
+		t.Fatalf(`Expected length of vals to be 2, got %d.`, len(vals))
+	}
+
+	if vals, _ := trie.Match([]byte("2014090")); len(vals) != 0 {
+		t.Fatalf(`Expected nothing to match a key that isn't there, got %v.`, vals)
+	}
+}
+
+func TestMatchWithCloseNeighbors(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
+	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
+	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
+
+	if vals, _ := trie.Match([]byte("prefix1:*:2015-05-*")); len(vals) != 3 {
+		t.Fatalf(`Expected length of vals to be 3, got %d.`, len(vals))
+	}
+
+	vals, _ := trie.Match([]byte("prefix1:prefix2:*"))
+
+	if len(vals) != 2 || vals["prefix1:prefix2:2015-05-30"] != "Friend" {
+		t.Fatalf(`Expected the two "prefix2" keys, got %v.`, vals)
+	}
+
+	if vals, _ := trie.Match([]byte("*-01")); len(vals) != 2 {
+		t.Fatalf(`Expected length of vals to be 2, got %d.`, len(vals))
+	}
+}
+
+func TestMatchEscapes(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("a*b"), 1)
+	trie.Insert([]byte("axb"), 2)
+	trie.Insert([]byte("a]"), 3)
+
+	if vals, _ := trie.Match([]byte(`a\*b`)); len(vals) != 1 || vals["a*b"] != 1 {
+		t.Fatalf(`Expected only "a*b" to match, got %v.`, vals)
+	}
+
+	if vals, _ := trie.Match([]byte(`a[\]]`)); len(vals) != 1 || vals["a]"] != 3 {
+		t.Fatalf(`Expected only "a]" to match, got %v.`, vals)
+	}
+}
+
+func TestMatchBadPattern(t *testing.T) {
+	trie := setupTrie()
+
+	for _, pattern := range []string{"[", "2014[0-", "2014\\", "[]", "[z-a]", "[a"} {
+		if _, err := trie.Match([]byte(pattern)); err != ErrBadPattern {
+			t.Fatalf(`Expected ErrBadPattern for %q, got %v.`, pattern, err)
+		}
+	}
+}
+
+func TestMatchPrunes(t *testing.T) {
+	trie := New().(*trieImpl)
+
+	for _, key := range generateKeys(4, "") {
+		trie.Insert([]byte(key), key)
+	}
+
+	tokens, _ := compileGlob([]byte("ab?d*"))
+	m := &countingMatcher{matcher: newGlobMatcher(tokens)}
+	trie.search(m, func(key []byte, val interface{}) bool {
+		return true
+	})
+
+	if nodes := countNodes(trie); m.pushes >= nodes/10 {
+		t.Fatalf(`Expected to look at a small part of the %d nodes, looked at %d.`, nodes, m.pushes)
+	}
+}
+
+// path.Match works the same way as long as there aren't any slashes around, so
+// we can check against it.
+func TestMatchMatchesPathMatch(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		pieces := []string{"a", "b", "0", "1", "?", "*", "[a-b]", "[^0]"}
+		keys := make([]string, 0)
+
+		for i := 0; i < 300; i++ {
+			key := make([]byte, rnd.Intn(6))
+
+			for j := range key {
+				key[j] = "ab01"[rnd.Intn(4)]
+			}
+
+			keys = append(keys, string(key))
+		}
+
+		for _, key := range keys {
+			trie.Insert([]byte(key), key)
+		}
+
+		for i := 0; i < 100; i++ {
+			pattern := ""
+
+			for j := rnd.Intn(5); j >= 0; j-- {
+				pattern += pieces[rnd.Intn(len(pieces))]
+			}
+
+			vals, err := trie.Match([]byte(pattern))
+
+			if err != nil {
+				t.Fatalf(`Expected no error for %q, got %v.`, pattern, err)
+			}
+
+			expected := 0
+
+			for it := trie.Iterator(); it.Valid(); it.Next() {
+				if ok, _ := path.Match(pattern, string(it.Key())); ok {
+					expected += 1
+
+					if vals[string(it.Key())] == nil {
+						t.Fatalf(`Expected %q to match %q.`, it.Key(), pattern)
+					}
+				}
+			}
+
+			if len(vals) != expected {
+				t.Fatalf(`Expected %d matches for %q, got %d.`, expected, pattern, len(vals))
+			}
+		}
+	})
+}
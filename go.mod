@@ -0,0 +1,3 @@
+module github.com/bradhe/trie
+
+go 1.19
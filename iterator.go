@@ -0,0 +1,339 @@
+package trie
+
+// An Iterator walks the keys of a Trie in byte order without having to build
+// up a map of the results first. A fresh iterator is positioned at the first
+// key, so the usual loop looks like this:
+//
+//	for it := t.Iterator(); it.Valid(); it.Next() {
+//		fmt.Println(string(it.Key()), it.Value())
+//	}
+//
+// The slice returned by Key is only good until the iterator is moved again, so
+// copy it if you need to hang on to it. Modifying the trie while iterating over
+// it is not supported.
+//
+// Reverse iterators work the same way, only backwards: they start out at the
+// last key, Seek finds the last key that is less than or equal to the one
+// given, and Next moves towards the front of the trie.
+type Iterator interface {
+	// Seek moves the iterator to the first key that is greater than or equal
+	// to key.
+	Seek(key []byte)
+
+	// Next moves the iterator to the following key.
+	Next()
+
+	// Valid reports whether the iterator is positioned at a key.
+	Valid() bool
+
+	Key() []byte
+	Value() interface{}
+}
+
+type trieIterator struct {
+	root    *trieImpl
+	reverse bool
+
+	// The revision we're reading at, if this is for a snapshot.
+	rev uint64
+
+	// The path from root down to the current node, along with the position
+	// of each node within its parent's children. An empty path means we ran
+	// off the end.
+	path    []*trieImpl
+	indexes []int
+
+	// The key for the current node.
+	key []byte
+}
+
+func newTrieIterator(root *trieImpl, rev uint64, reverse bool) *trieIterator {
+	it := &trieIterator{root: root, rev: rev, reverse: reverse}
+	it.reset()
+
+	if reverse {
+		it.last()
+	}
+
+	it.settle()
+	return it
+}
+
+func (self *trieIterator) reset() {
+	self.path = append(self.path[:0], self.root)
+	self.indexes = append(self.indexes[:0], -1)
+	self.key = self.key[:0]
+}
+
+func (self *trieIterator) current() *trieImpl {
+	return self.path[len(self.path)-1]
+}
+
+func (self *trieIterator) descend(i int) {
+	child := self.current().children[i]
+	self.path = append(self.path, child)
+	self.indexes = append(self.indexes, i)
+	self.key = append(self.key, child.key)
+}
+
+// skip moves on to the next sibling of the current node, or the next sibling of
+// the closest ancestor that has one, without visiting anything underneath the
+// current node.
+func (self *trieIterator) skip() {
+	for len(self.path) > 1 {
+		i := self.indexes[len(self.indexes)-1]
+
+		self.path = self.path[:len(self.path)-1]
+		self.indexes = self.indexes[:len(self.indexes)-1]
+		self.key = self.key[:len(self.key)-1]
+
+		if i+1 < len(self.current().children) {
+			self.descend(i + 1)
+			return
+		}
+	}
+
+	// Nothing left, we've walked off the end of the trie.
+	self.path = self.path[:0]
+}
+
+// advance moves to the next node in order, regardless of whether or not it has
+// a value.
+func (self *trieIterator) advance() {
+	if len(self.current().children) > 0 {
+		self.descend(0)
+	} else {
+		self.skip()
+	}
+}
+
+// last descends to the very last node underneath the current one.
+func (self *trieIterator) last() {
+	for len(self.current().children) > 0 {
+		self.descend(len(self.current().children) - 1)
+	}
+}
+
+// retreat moves to the previous node in order. Parents come before their
+// children, so that's either the tail end of the previous sibling or the
+// parent itself.
+func (self *trieIterator) retreat() {
+	if len(self.path) < 2 {
+		self.path = self.path[:0]
+		return
+	}
+
+	i := self.indexes[len(self.indexes)-1]
+
+	self.path = self.path[:len(self.path)-1]
+	self.indexes = self.indexes[:len(self.indexes)-1]
+	self.key = self.key[:len(self.key)-1]
+
+	if i > 0 {
+		self.descend(i - 1)
+		self.last()
+	}
+}
+
+// settle moves along until we land on a node that actually holds a value.
+func (self *trieIterator) settle() {
+	for self.Valid() && !self.current().has(self.rev) {
+		if self.reverse {
+			self.retreat()
+		} else {
+			self.advance()
+		}
+	}
+}
+
+func (self *trieIterator) Seek(key []byte) {
+	self.reset()
+
+	if self.reverse {
+		self.seekReverse(key)
+		return
+	}
+
+	for _, b := range key {
+		node := self.current()
+		pos := -1
+
+		for i, child := range node.children {
+			if child.key >= b {
+				pos = i
+				break
+			}
+		}
+
+		// Everything under this node comes before the key.
+		if pos < 0 {
+			self.skip()
+			self.settle()
+			return
+		}
+
+		self.descend(pos)
+
+		// Everything under this child comes after the key, so the child is
+		// where we want to start.
+		if node.children[pos].key > b {
+			self.settle()
+			return
+		}
+	}
+
+	// We matched the key exactly.
+	self.settle()
+}
+
+func (self *trieIterator) seekReverse(key []byte) {
+	for _, b := range key {
+		node := self.current()
+		pos := -1
+
+		for i := len(node.children) - 1; i >= 0; i-- {
+			if node.children[i].key <= b {
+				pos = i
+				break
+			}
+		}
+
+		// Everything underneath this node comes after the key, which leaves
+		// the node itself as the best candidate.
+		if pos < 0 {
+			self.settle()
+			return
+		}
+
+		self.descend(pos)
+
+		// Everything under this child comes before the key, so the last of
+		// them is where we want to be.
+		if node.children[pos].key < b {
+			self.last()
+			self.settle()
+			return
+		}
+	}
+
+	// We matched the key exactly. Anything below us is bigger than the key,
+	// so it's either this node or whatever came before it.
+	self.settle()
+}
+
+func (self *trieIterator) Next() {
+	if !self.Valid() {
+		return
+	}
+
+	if self.reverse {
+		self.retreat()
+	} else {
+		self.advance()
+	}
+
+	self.settle()
+}
+
+func (self *trieIterator) Valid() bool {
+	return len(self.path) > 0
+}
+
+func (self *trieIterator) Key() []byte {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.key
+}
+
+func (self *trieIterator) Value() interface{} {
+	if !self.Valid() {
+		return nil
+	}
+
+	val, _ := self.current().at(self.rev)
+	return val
+}
+
+// A navigator can find the keys on either side of any other key. That's all it
+// takes to build an iterator that doesn't hold on to anything inside the trie
+// between calls.
+type navigator interface {
+	// ceiling returns the first key after key, or equal to it if inclusive.
+	ceiling(key []byte, inclusive bool) ([]byte, interface{}, bool)
+
+	// floor returns the last key before key, or equal to it if inclusive.
+	floor(key []byte, inclusive bool) ([]byte, interface{}, bool)
+
+	last() ([]byte, interface{}, bool)
+}
+
+// seekIterator steps from key to key by looking each one up from scratch. It's
+// slower than walking the nodes, but it never looks at a node once the call
+// that found it is done, so it's fine for the trie to change in between.
+type seekIterator struct {
+	nav     navigator
+	reverse bool
+	valid   bool
+	key     []byte
+	value   interface{}
+}
+
+func newSeekIterator(nav navigator, reverse bool) *seekIterator {
+	it := &seekIterator{nav: nav, reverse: reverse}
+
+	if reverse {
+		it.set(nav.last())
+	} else {
+		it.set(nav.ceiling([]byte{}, true))
+	}
+
+	return it
+}
+
+func (self *seekIterator) set(key []byte, val interface{}, ok bool) {
+	self.key = key
+	self.value = val
+	self.valid = ok
+}
+
+func (self *seekIterator) Seek(key []byte) {
+	if self.reverse {
+		self.set(self.nav.floor(key, true))
+	} else {
+		self.set(self.nav.ceiling(key, true))
+	}
+}
+
+func (self *seekIterator) Next() {
+	if !self.valid {
+		return
+	}
+
+	if self.reverse {
+		self.set(self.nav.floor(self.key, false))
+	} else {
+		self.set(self.nav.ceiling(self.key, false))
+	}
+}
+
+func (self *seekIterator) Valid() bool {
+	return self.valid
+}
+
+func (self *seekIterator) Key() []byte {
+	if !self.valid {
+		return nil
+	}
+
+	return self.key
+}
+
+func (self *seekIterator) Value() interface{} {
+	if !self.valid {
+		return nil
+	}
+
+	return self.value
+}
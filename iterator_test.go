@@ -0,0 +1,192 @@
+package trie
+
+import (
+	"testing"
+)
+
+func TestIteratorWalksKeysInOrder(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("test2"), "World")
+	trie.Insert([]byte("test"), "Hello")
+	trie.Insert([]byte("test10"), "Again")
+	trie.Insert([]byte("abc"), "First")
+
+	expected := []string{"abc", "test", "test10", "test2"}
+	keys := make([]string, 0)
+
+	for it := trie.Iterator(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+
+	if len(keys) != len(expected) {
+		t.Fatalf(`Expected %d keys, got %d (%v).`, len(expected), len(keys), keys)
+	}
+
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Fatalf(`Expected key %d to be "%s", got "%s".`, i, expected[i], keys[i])
+		}
+	}
+}
+
+func TestIteratorValues(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("test1"), "Hello")
+	trie.Insert([]byte("test2"), "World")
+
+	it := trie.Iterator()
+
+	if it.Value() != "Hello" {
+		t.Fatalf(`Expected value to be "Hello", got "%v".`, it.Value())
+	}
+
+	it.Next()
+
+	if it.Value() != "World" {
+		t.Fatalf(`Expected value to be "World", got "%v".`, it.Value())
+	}
+
+	it.Next()
+
+	if it.Valid() {
+		t.Fatalf(`Expected iterator to be exhausted, was at "%s".`, it.Key())
+	}
+}
+
+func TestIteratorEmptyTrie(t *testing.T) {
+	it := New().Iterator()
+
+	if it.Valid() {
+		t.Fatalf(`Expected iterator over an empty trie to be invalid.`)
+	}
+
+	it.Seek([]byte("test"))
+
+	if it.Valid() {
+		t.Fatalf(`Expected iterator over an empty trie to be invalid.`)
+	}
+}
+
+func TestIteratorSeek(t *testing.T) {
+	trie := setupTrie()
+	it := trie.Iterator()
+
+	it.Seek([]byte("20140905"))
+
+	if string(it.Key()) != "20140905" {
+		t.Fatalf(`Expected key to be "20140905", got "%s".`, it.Key())
+	}
+
+	// Seeking to something that isn't there should land on the next key.
+	it.Seek([]byte("201409055"))
+
+	if string(it.Key()) != "20140906" {
+		t.Fatalf(`Expected key to be "20140906", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("2014"))
+
+	if string(it.Key()) != "20140901" {
+		t.Fatalf(`Expected key to be "20140901", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("20140912"))
+
+	if it.Valid() {
+		t.Fatalf(`Expected iterator to be exhausted, was at "%s".`, it.Key())
+	}
+}
+
+func TestIteratorSeekRange(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
+	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
+	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
+
+	count := 0
+	end := "prefix1:prefix2:2015-05-30"
+	it := trie.Iterator()
+
+	for it.Seek([]byte("prefix1:prefix2:")); it.Valid() && string(it.Key()) <= end; it.Next() {
+		count++
+	}
+
+	if count != 2 {
+		t.Fatalf(`Expected to visit 2 keys, visited %d.`, count)
+	}
+}
+
+func TestReverseIteratorWalksKeysBackwards(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("test2"), "World")
+	trie.Insert([]byte("test"), "Hello")
+	trie.Insert([]byte("test10"), "Again")
+	trie.Insert([]byte("abc"), "First")
+
+	expected := []string{"test2", "test10", "test", "abc"}
+	keys := make([]string, 0)
+
+	for it := trie.ReverseIterator(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+
+	if len(keys) != len(expected) {
+		t.Fatalf(`Expected %d keys, got %d (%v).`, len(expected), len(keys), keys)
+	}
+
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Fatalf(`Expected key %d to be "%s", got "%s".`, i, expected[i], keys[i])
+		}
+	}
+}
+
+func TestReverseIteratorSeek(t *testing.T) {
+	trie := setupTrie()
+	it := trie.ReverseIterator()
+
+	if string(it.Key()) != "20140911" {
+		t.Fatalf(`Expected key to be "20140911", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("20140905"))
+
+	if string(it.Key()) != "20140905" {
+		t.Fatalf(`Expected key to be "20140905", got "%s".`, it.Key())
+	}
+
+	// Seeking to something that isn't there should land on the key before.
+	it.Seek([]byte("201409055"))
+
+	if string(it.Key()) != "20140905" {
+		t.Fatalf(`Expected key to be "20140905", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("2015"))
+
+	if string(it.Key()) != "20140911" {
+		t.Fatalf(`Expected key to be "20140911", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("2014"))
+
+	if it.Valid() {
+		t.Fatalf(`Expected iterator to be exhausted, was at "%s".`, it.Key())
+	}
+}
+
+func TestIteratorNilValues(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("test1"), nil)
+	trie.Insert([]byte("test2"), "World")
+
+	it := trie.Iterator()
+
+	if !it.Valid() || string(it.Key()) != "test1" {
+		t.Fatalf(`Expected iterator to start at "test1".`)
+	}
+
+	if it.Value() != nil {
+		t.Fatalf(`Expected "test1" to be nil, got %v.`, it.Value())
+	}
+}
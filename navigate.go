@@ -0,0 +1,308 @@
+package trie
+
+import (
+	"sort"
+)
+
+// navNode is a node that navFirst, navLast, navCeiling and navFloor can find
+// their way around, so that backends with the same shape of node can share
+// them. The children have to be in key order.
+type navNode interface {
+	// navValue returns the node's own value, if it has one.
+	navValue() (interface{}, bool)
+
+	navLen() int
+
+	// navSearch returns the position of the first child with a key >= b.
+	navSearch(b byte) int
+
+	// navEnter gets the i'th child ready to be read and returns it with its
+	// key, or nil if there's nothing under it. navLeave has to be called on
+	// it afterwards.
+	navEnter(i int) (navNode, byte)
+	navLeave()
+}
+
+// navFirst finds the smallest key under node, appending it to path.
+func navFirst(node navNode, path []byte) ([]byte, interface{}, bool) {
+	if val, ok := node.navValue(); ok {
+		return path, val, true
+	}
+
+	return navFirstChild(node, path)
+}
+
+// navFirstChild finds the smallest key under any of node's children.
+func navFirstChild(node navNode, path []byte) ([]byte, interface{}, bool) {
+	for i := 0; i < node.navLen(); i++ {
+		child, b := node.navEnter(i)
+
+		if child == nil {
+			continue
+		}
+
+		k, v, ok := navFirst(child, append(path, b))
+		child.navLeave()
+
+		if ok {
+			return k, v, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// navLast finds the biggest key under node. Children come after the node
+// itself, so they go first.
+func navLast(node navNode, path []byte) ([]byte, interface{}, bool) {
+	for i := node.navLen() - 1; i >= 0; i-- {
+		child, b := node.navEnter(i)
+
+		if child == nil {
+			continue
+		}
+
+		k, v, ok := navLast(child, append(path, b))
+		child.navLeave()
+
+		if ok {
+			return k, v, true
+		}
+	}
+
+	if val, ok := node.navValue(); ok {
+		return path, val, true
+	}
+
+	return nil, nil, false
+}
+
+// navCeiling finds the smallest key under node that comes after key (or is
+// equal to it, if inclusive). key is relative to node. We follow key down as
+// far as it goes, and whenever that comes up empty try the next child along,
+// where the smallest key will do.
+func navCeiling(node navNode, path, key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	if len(key) == 0 {
+		if val, ok := node.navValue(); ok && inclusive {
+			return path, val, true
+		}
+
+		// We're at key, so anything further down is bigger than it.
+		return navFirstChild(node, path)
+	}
+
+	// node's own key is shorter than key, so it's too small. Only children
+	// from key[0] on can have anything.
+	for i := node.navSearch(key[0]); i < node.navLen(); i++ {
+		child, b := node.navEnter(i)
+
+		if child == nil {
+			continue
+		}
+
+		var k []byte
+		var v interface{}
+		var ok bool
+
+		if b == key[0] {
+			k, v, ok = navCeiling(child, append(path, b), key[1:], inclusive)
+		} else {
+			k, v, ok = navFirst(child, append(path, b))
+		}
+
+		child.navLeave()
+
+		if ok {
+			return k, v, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// navFloor finds the biggest key under node that comes before key (or is
+// equal to it, if inclusive). It's navCeiling the other way round, except
+// that node's own value comes last rather than first.
+func navFloor(node navNode, path, key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	if len(key) == 0 {
+		// Anything further down would be bigger than key, so it's this
+		// node or nothing.
+		if val, ok := node.navValue(); ok && inclusive {
+			return path, val, true
+		}
+
+		return nil, nil, false
+	}
+
+	// Children from key[0] + 1 on are all too big.
+	i := node.navSearch(key[0])
+
+	if i == node.navLen() {
+		i -= 1
+	}
+
+	for ; i >= 0; i-- {
+		child, b := node.navEnter(i)
+
+		if child == nil {
+			continue
+		}
+
+		var k []byte
+		var v interface{}
+		var ok bool
+
+		if b == key[0] {
+			k, v, ok = navFloor(child, append(path, b), key[1:], inclusive)
+		} else if b < key[0] {
+			k, v, ok = navLast(child, append(path, b))
+		}
+
+		child.navLeave()
+
+		if ok {
+			return k, v, true
+		}
+	}
+
+	// node's own key is shorter than key, so it comes before it.
+	if val, ok := node.navValue(); ok {
+		return path, val, true
+	}
+
+	return nil, nil, false
+}
+
+func (self *trieImpl) navValue() (interface{}, bool) {
+	return self.value, self.hasValue
+}
+
+func (self *trieImpl) navLen() int {
+	return len(self.children)
+}
+
+func (self *trieImpl) navSearch(b byte) int {
+	return sort.Search(len(self.children), func(i int) bool {
+		return self.children[i].key >= b
+	})
+}
+
+// Nodes that are only hanging around for snapshots don't have anything in
+// them, and nothing else can be writing to the trie while we're reading it.
+func (self *trieImpl) navEnter(i int) (navNode, byte) {
+	child := self.children[i]
+
+	if child.size == 0 {
+		return nil, child.key
+	}
+
+	return child, child.key
+}
+
+func (self *trieImpl) navLeave() {}
+
+func (self *trieImpl) ceiling(key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	return navCeiling(self, []byte{}, key, inclusive)
+}
+
+func (self *trieImpl) floor(key []byte, inclusive bool) ([]byte, interface{}, bool) {
+	return navFloor(self, []byte{}, key, inclusive)
+}
+
+func (self *trieImpl) last() ([]byte, interface{}, bool) {
+	return navLast(self, []byte{})
+}
+
+// Min returns the smallest key in the trie, if there are any.
+func (self *trieImpl) Min() ([]byte, interface{}, bool) {
+	return self.ceiling([]byte{}, true)
+}
+
+// Max returns the biggest key in the trie, if there are any.
+func (self *trieImpl) Max() ([]byte, interface{}, bool) {
+	return self.last()
+}
+
+// Floor returns the biggest key that's no bigger than key.
+func (self *trieImpl) Floor(key []byte) ([]byte, interface{}, bool) {
+	return self.floor(key, true)
+}
+
+// Ceiling returns the smallest key that's no smaller than key.
+func (self *trieImpl) Ceiling(key []byte) ([]byte, interface{}, bool) {
+	return self.ceiling(key, true)
+}
+
+// Prev returns the biggest key that comes before key. key doesn't have to be
+// in the trie.
+func (self *trieImpl) Prev(key []byte) ([]byte, interface{}, bool) {
+	return self.floor(key, false)
+}
+
+// Next returns the smallest key that comes after key. key doesn't have to be
+// in the trie.
+func (self *trieImpl) Next(key []byte) ([]byte, interface{}, bool) {
+	return self.ceiling(key, false)
+}
+
+// near finds the first key inside b going whichever way it goes, using the
+// store's own navigation if it has any.
+func (self *ordered) near(b Bound, reverse bool) ([]byte, interface{}, bool) {
+	if nav, ok := self.store.(navigator); ok {
+		if reverse {
+			return nav.floor(b.Key, b.Kind == Inclusive)
+		}
+
+		return nav.ceiling(b.Key, b.Kind == Inclusive)
+	}
+
+	it := self.Iterator()
+
+	if reverse {
+		it = self.ReverseIterator()
+	}
+
+	seekBound(it, b)
+
+	if !it.Valid() {
+		return nil, nil, false
+	}
+
+	return append([]byte{}, it.Key()...), it.Value(), true
+}
+
+func (self *ordered) Min() ([]byte, interface{}, bool) {
+	return self.near(InclusiveBound([]byte{}), false)
+}
+
+func (self *ordered) Max() ([]byte, interface{}, bool) {
+	if nav, ok := self.store.(navigator); ok {
+		return nav.last()
+	}
+
+	// Fresh reverse iterators are already at the biggest key, so there's
+	// nothing to seek to.
+	it := self.ReverseIterator()
+
+	if !it.Valid() {
+		return nil, nil, false
+	}
+
+	return append([]byte{}, it.Key()...), it.Value(), true
+}
+
+func (self *ordered) Floor(key []byte) ([]byte, interface{}, bool) {
+	return self.near(InclusiveBound(key), true)
+}
+
+func (self *ordered) Ceiling(key []byte) ([]byte, interface{}, bool) {
+	return self.near(InclusiveBound(key), false)
+}
+
+func (self *ordered) Prev(key []byte) ([]byte, interface{}, bool) {
+	return self.near(ExclusiveBound(key), true)
+}
+
+func (self *ordered) Next(key []byte) ([]byte, interface{}, bool) {
+	return self.near(ExclusiveBound(key), false)
+}
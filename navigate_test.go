@@ -0,0 +1,158 @@
+package trie
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestNavigate(t *testing.T) {
+	trie := setupTrie()
+
+	if key, val, ok := trie.Min(); !ok || string(key) != "20140901" || val != "20140901" {
+		t.Fatalf(`Expected min to be "20140901", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Max(); !ok || string(key) != "20140911" {
+		t.Fatalf(`Expected max to be "20140911", got %q.`, key)
+	}
+
+	// Somewhere in between two keys.
+	if key, _, ok := trie.Floor([]byte("20140905120000")); !ok || string(key) != "20140905" {
+		t.Fatalf(`Expected floor to be "20140905", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Ceiling([]byte("20140905120000")); !ok || string(key) != "20140906" {
+		t.Fatalf(`Expected ceiling to be "20140906", got %q.`, key)
+	}
+
+	// Right on a key.
+	if key, _, ok := trie.Floor([]byte("20140905")); !ok || string(key) != "20140905" {
+		t.Fatalf(`Expected floor to be "20140905", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Ceiling([]byte("20140905")); !ok || string(key) != "20140905" {
+		t.Fatalf(`Expected ceiling to be "20140905", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Prev([]byte("20140905")); !ok || string(key) != "20140904" {
+		t.Fatalf(`Expected prev to be "20140904", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Next([]byte("20140905")); !ok || string(key) != "20140906" {
+		t.Fatalf(`Expected next to be "20140906", got %q.`, key)
+	}
+
+	// Off either end.
+	if key, _, ok := trie.Prev([]byte("20140901")); ok {
+		t.Fatalf(`Expected nothing before "20140901", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Next([]byte("20140911")); ok {
+		t.Fatalf(`Expected nothing after "20140911", got %q.`, key)
+	}
+}
+
+func TestNavigateEmpty(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, _ *rand.Rand) {
+		if _, _, ok := trie.Min(); ok {
+			t.Fatalf(`Expected no min in an empty trie.`)
+		}
+
+		if _, _, ok := trie.Max(); ok {
+			t.Fatalf(`Expected no max in an empty trie.`)
+		}
+
+		if _, _, ok := trie.Floor([]byte("a")); ok {
+			t.Fatalf(`Expected no floor in an empty trie.`)
+		}
+	})
+}
+
+// Every backend, and snapshots of them, should find the same keys as searching
+// through a sorted list of them.
+func TestNavigateMatchesSort(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		keys := make(map[string]bool)
+
+		for i := 0; i < 200; i++ {
+			key := randomBinaryKey(rnd)
+			trie.Insert(key, string(key))
+			keys[string(key)] = true
+		}
+
+		// Snapshots keep deleted nodes around, which should get skipped
+		// over.
+		snap := trie.Snapshot()
+
+		for i := 0; i < 50; i++ {
+			key := randomBinaryKey(rnd)
+			trie.Delete(key)
+			delete(keys, string(key))
+		}
+
+		sorted := make([]string, 0, len(keys))
+
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+
+		sort.Strings(sorted)
+
+		check := func(what string, key []byte, i int, got []byte, val interface{}, ok bool) {
+			if i < 0 || i >= len(sorted) {
+				if ok {
+					t.Fatalf(`Expected no %s for %q, got %q.`, what, key, got)
+				}
+
+				return
+			}
+
+			if !ok || string(got) != sorted[i] || val != sorted[i] {
+				t.Fatalf(`Expected %s of %q to be %q, got %q.`, what, key, sorted[i], got)
+			}
+		}
+
+		k, v, ok := trie.Min()
+		check("min", nil, 0, k, v, ok)
+		k, v, ok = trie.Max()
+		check("max", nil, len(sorted)-1, k, v, ok)
+
+		for i := 0; i < 200; i++ {
+			key := randomBinaryKey(rnd)
+
+			// The first key that's not smaller, and the first that's
+			// bigger.
+			ceil := sort.SearchStrings(sorted, string(key))
+			next := ceil
+
+			if next < len(sorted) && sorted[next] == string(key) {
+				next += 1
+			}
+
+			k, v, ok := trie.Ceiling(key)
+			check("ceiling", key, ceil, k, v, ok)
+			k, v, ok = trie.Next(key)
+			check("next", key, next, k, v, ok)
+			k, v, ok = trie.Floor(key)
+			check("floor", key, next-1, k, v, ok)
+			k, v, ok = trie.Prev(key)
+			check("prev", key, ceil-1, k, v, ok)
+		}
+
+		snap.Release()
+	})
+}
+
+func TestPersistentNavigate(t *testing.T) {
+	first := NewPersistent().Insert([]byte("a"), 1).Insert([]byte("c"), 3)
+	second := first.Insert([]byte("b"), 2)
+
+	if key, _, _ := first.Next([]byte("a")); string(key) != "c" {
+		t.Fatalf(`Expected "c" after "a", got %q.`, key)
+	}
+
+	if key, _, _ := second.Next([]byte("a")); string(key) != "b" {
+		t.Fatalf(`Expected "b" after "a", got %q.`, key)
+	}
+}
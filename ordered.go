@@ -0,0 +1,334 @@
+package trie
+
+import (
+	"bytes"
+)
+
+// store is the handful of operations a backend has to provide for itself.
+// ordered fills in the rest of the Trie interface on top of them by walking the
+// backend's iterators, which works for anything that keeps its keys sorted.
+//
+// Weights are kept on the backend's own nodes next to the values, so that
+// TopK can find the heaviest keys without looking at all of them.
+type store interface {
+	Insert(key []byte, val interface{})
+	InsertWeighted(key []byte, val interface{}, weight float64)
+	Get(key []byte) (interface{}, bool)
+	weigh(key []byte) float64
+	topK(prefix []byte, k int) []Completion
+
+	// Every backend knows how big its subtrees are, so Rank and Select
+	// can skip over whole subtrees the same way trieImpl does.
+	rank(key []byte) int
+	selectAt(i int) ([]byte, interface{}, bool)
+	Delete(key []byte) bool
+	Iterator() Iterator
+	ReverseIterator() Iterator
+	Len() int
+}
+
+// Backends can implement any of these if they can do better than walking an
+// iterator.
+type prefixCounter interface {
+	CountPrefix(prefix []byte) int
+}
+
+type prefixDeleter interface {
+	DeletePrefix(prefix []byte) int
+}
+
+// swapper is for backends that need to replace their contents in place rather
+// than being replaced.
+type swapper interface {
+	swap(fresh store)
+}
+
+// Backends that can be written to while they're being read implement holder,
+// so that reads of more than one key don't see transactions half done. hold
+// returns the function that lets go again.
+type holder interface {
+	hold() func()
+}
+
+type ordered struct {
+	store
+	opts *options
+}
+
+// prefixEnd returns the smallest key that is bigger than every key starting
+// with prefix, or nil if there isn't one (i.e. the prefix is all 0xFF).
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xFF {
+			end[i] += 1
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// seekPrefixReverse positions a reverse iterator on the last key starting with
+// prefix, if there is one.
+func seekPrefixReverse(it Iterator, prefix []byte) {
+	end := prefixEnd(prefix)
+
+	// Fresh reverse iterators are already at the very last key.
+	if end == nil {
+		return
+	}
+
+	it.Seek(end)
+
+	if it.Valid() && bytes.Equal(it.Key(), end) {
+		it.Next()
+	}
+}
+
+func (self *ordered) hold() func() {
+	if h, ok := self.store.(holder); ok {
+		return h.hold()
+	}
+
+	return func() {}
+}
+
+func (self *ordered) Lookup(key []byte) interface{} {
+	val, _ := self.Get(key)
+	return val
+}
+
+func (self *ordered) Has(key []byte) bool {
+	_, ok := self.Get(key)
+	return ok
+}
+
+// We don't have a way to walk down towards a key for every backend, so we look
+// up each of its prefixes in turn.
+func (self *ordered) LongestPrefix(key []byte) ([]byte, interface{}, bool) {
+	defer self.hold()()
+
+	for n := len(key); n >= 0; n-- {
+		if val, ok := self.Get(key[:n]); ok {
+			return append([]byte{}, key[:n]...), val, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func (self *ordered) AllPrefixesOf(key []byte) map[string]interface{} {
+	defer self.hold()()
+
+	res := make(map[string]interface{})
+
+	for n := 0; n <= len(key); n++ {
+		if val, ok := self.Get(key[:n]); ok {
+			res[string(key[:n])] = val
+		}
+	}
+
+	return res
+}
+
+func (self *ordered) Count() int {
+	return self.Len()
+}
+
+func (self *ordered) Range(start, end []byte) map[string]interface{} {
+	return self.RangeN(start, end, -1)
+}
+
+func (self *ordered) RangeN(start, end []byte, n int) map[string]interface{} {
+	return self.OffsetRangeN([]byte{}, start, end, n)
+}
+
+func (self *ordered) OffsetRangeN(offset, start, end []byte, n int) map[string]interface{} {
+	return self.BoundedRangeN(offset, Between(start, end), n)
+}
+
+func (self *ordered) OffsetRangeKV(offset, start, end []byte, n int) []KV {
+	return self.BoundedRangeKV(offset, Between(start, end), n)
+}
+
+func (self *ordered) BoundedRangeN(offset []byte, bounds Bounds, n int) map[string]interface{} {
+	defer self.hold()()
+
+	res := make(map[string]interface{})
+	boundedRange(self.Iterator(), offset, bounds, intoMap(res), n)
+	return res
+}
+
+func (self *ordered) BoundedRangeKV(offset []byte, bounds Bounds, n int) []KV {
+	defer self.hold()()
+
+	res := make([]KV, 0)
+	boundedRange(self.Iterator(), offset, bounds, intoSlice(&res), n)
+	return res
+}
+
+func (self *ordered) ReverseRangeN(start, end []byte, n int) map[string]interface{} {
+	return self.ReverseBoundedRangeN(Between(start, end), n)
+}
+
+func (self *ordered) ReverseRangeKV(start, end []byte, n int) []KV {
+	return self.ReverseBoundedRangeKV(Between(start, end), n)
+}
+
+func (self *ordered) ReverseBoundedRangeN(bounds Bounds, n int) map[string]interface{} {
+	defer self.hold()()
+
+	res := make(map[string]interface{})
+	boundedReverseRange(self.ReverseIterator(), bounds, intoMap(res), n)
+	return res
+}
+
+func (self *ordered) ReverseBoundedRangeKV(bounds Bounds, n int) []KV {
+	defer self.hold()()
+
+	res := make([]KV, 0)
+	boundedReverseRange(self.ReverseIterator(), bounds, intoSlice(&res), n)
+	return res
+}
+
+func (self *ordered) Prefix(prefix []byte) map[string]interface{} {
+	return self.PrefixN(prefix, -1)
+}
+
+func (self *ordered) PrefixN(prefix []byte, n int) map[string]interface{} {
+	return self.OffsetPrefixN([]byte{}, prefix, n)
+}
+
+func (self *ordered) doPrefix(offset, prefix []byte, emit emitter, n int) {
+	defer self.hold()()
+
+	it := self.Iterator()
+
+	for it.Seek(prefix); it.Valid() && n != 0; it.Next() {
+		if !bytes.HasPrefix(it.Key(), prefix) {
+			break
+		}
+
+		if isOffsetLesser(offset, it.Key()) {
+			emit(it.Key(), it.Value())
+
+			if n > 0 {
+				n -= 1
+			}
+		}
+	}
+}
+
+func (self *ordered) OffsetPrefixN(offset, prefix []byte, n int) map[string]interface{} {
+	res := make(map[string]interface{})
+	self.doPrefix(offset, prefix, intoMap(res), n)
+	return res
+}
+
+func (self *ordered) OffsetPrefixKV(offset, prefix []byte, n int) []KV {
+	res := make([]KV, 0)
+	self.doPrefix(offset, prefix, intoSlice(&res), n)
+	return res
+}
+
+func (self *ordered) doReversePrefix(prefix []byte, emit emitter, n int) {
+	defer self.hold()()
+
+	it := self.ReverseIterator()
+
+	for seekPrefixReverse(it, prefix); it.Valid() && n != 0; it.Next() {
+		if !bytes.HasPrefix(it.Key(), prefix) {
+			break
+		}
+
+		emit(it.Key(), it.Value())
+
+		if n > 0 {
+			n -= 1
+		}
+	}
+}
+
+func (self *ordered) ReversePrefixN(prefix []byte, n int) map[string]interface{} {
+	res := make(map[string]interface{})
+	self.doReversePrefix(prefix, intoMap(res), n)
+	return res
+}
+
+func (self *ordered) ReversePrefixKV(prefix []byte, n int) []KV {
+	res := make([]KV, 0)
+	self.doReversePrefix(prefix, intoSlice(&res), n)
+	return res
+}
+
+func (self *ordered) CountPrefix(prefix []byte) int {
+	if c, ok := self.store.(prefixCounter); ok {
+		return c.CountPrefix(prefix)
+	}
+
+	defer self.hold()()
+
+	count := 0
+	it := self.Iterator()
+
+	for it.Seek(prefix); it.Valid() && bytes.HasPrefix(it.Key(), prefix); it.Next() {
+		count += 1
+	}
+
+	return count
+}
+
+func (self *ordered) CountRange(start, end []byte) int {
+	defer self.hold()()
+
+	count := 0
+	it := self.Iterator()
+
+	for it.Seek(start); it.Valid() && bytes.Compare(it.Key(), end) <= 0; it.Next() {
+		count += 1
+	}
+
+	return count
+}
+
+// deleteKeys removes everything the iterator visits while keep returns true,
+// using del. We can't delete out from under the iterator, so the keys get
+// collected up first. Going one key at a time also means snapshots get to keep
+// what they need.
+func deleteKeys(it Iterator, del func(key []byte) bool, keep func(key []byte) bool) int {
+	keys := make([][]byte, 0)
+
+	for ; it.Valid() && keep(it.Key()); it.Next() {
+		keys = append(keys, append([]byte{}, it.Key()...))
+	}
+
+	for _, key := range keys {
+		del(key)
+	}
+
+	return len(keys)
+}
+
+func (self *ordered) DeletePrefix(prefix []byte) int {
+	if d, ok := self.store.(prefixDeleter); ok {
+		return d.DeletePrefix(prefix)
+	}
+
+	it := self.Iterator()
+	it.Seek(prefix)
+
+	return deleteKeys(it, self.Delete, func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	})
+}
+
+func (self *ordered) DeleteRange(start, end []byte) int {
+	it := self.Iterator()
+	it.Seek(start)
+
+	return deleteKeys(it, self.Delete, func(key []byte) bool {
+		return bytes.Compare(key, end) <= 0
+	})
+}
@@ -0,0 +1,124 @@
+package trie
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrBadCursor is returned by RangePage, BoundedRangePage and PrefixPage when
+// the cursor isn't one they handed out.
+var ErrBadCursor = errors.New("trie: malformed cursor")
+
+// cursorVersion goes on the front of every cursor, so we can tell our own
+// cursors apart from junk and change the format later if we have to.
+const cursorVersion = 1
+
+// A Page is one page of results from RangePage or PrefixPage.
+type Page struct {
+	Items []KV
+
+	// Cursor picks up right after the last item, or is empty if there's
+	// nothing left. It's safe to put in a URL, but it's only the last key
+	// base64'd with a version byte in front, so anyone holding it can read
+	// that key back out. Don't hand it to clients that shouldn't see keys.
+	Cursor string
+}
+
+func encodeCursor(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(append([]byte{cursorVersion}, key...))
+}
+
+// decodeCursor returns the key a cursor was made from, or nil for the empty
+// cursor.
+func decodeCursor(cursor string) ([]byte, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil || len(data) < 1 || data[0] != cursorVersion {
+		return nil, ErrBadCursor
+	}
+
+	return data[1:], nil
+}
+
+// page reads up to n keys (or all of them if n is negative) from the first key
+// after the cursor onwards, for as long as keep says so. The cursor only
+// holds on to the last key we handed back rather than a position, so it
+// still works after the trie has been written to.
+func page(it Iterator, start Bound, cursor string, n int, keep func(key []byte) bool) (Page, error) {
+	after, err := decodeCursor(cursor)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	res := Page{Items: make([]KV, 0)}
+
+	if n == 0 {
+		res.Cursor = cursor
+		return res, nil
+	}
+
+	// The cursor is just another start, and we go from whichever is later.
+	if after != nil && (Bounds{Start: start}).afterStart(after) {
+		start = ExclusiveBound(after)
+	}
+
+	for seekBound(it, start); it.Valid() && keep(it.Key()); it.Next() {
+		if len(res.Items) == n {
+			res.Cursor = encodeCursor(res.Items[n-1].Key)
+			break
+		}
+
+		res.Items = append(res.Items, KV{append([]byte{}, it.Key()...), it.Value()})
+	}
+
+	return res, nil
+}
+
+func rangePage(it Iterator, bounds Bounds, cursor string, n int) (Page, error) {
+	return page(it, bounds.Start, cursor, n, bounds.beforeEnd)
+}
+
+func prefixPage(it Iterator, prefix []byte, cursor string, n int) (Page, error) {
+	return page(it, InclusiveBound(prefix), cursor, n, func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	})
+}
+
+// RangePage returns up to n keys between start and end, inclusive, starting
+// after cursor. Pass an empty cursor for the first page, then the Cursor of
+// each page for the one after it until it comes back empty.
+func (self *trieImpl) RangePage(start, end []byte, cursor string, n int) (Page, error) {
+	return self.BoundedRangePage(Between(start, end), cursor, n)
+}
+
+// BoundedRangePage is RangePage with more say over where the range starts and
+// ends.
+func (self *trieImpl) BoundedRangePage(bounds Bounds, cursor string, n int) (Page, error) {
+	return rangePage(self.Iterator(), bounds, cursor, n)
+}
+
+// PrefixPage returns up to n keys that start with prefix, starting after
+// cursor, the same way as RangePage.
+func (self *trieImpl) PrefixPage(prefix []byte, cursor string, n int) (Page, error) {
+	return prefixPage(self.Iterator(), prefix, cursor, n)
+}
+
+func (self *ordered) RangePage(start, end []byte, cursor string, n int) (Page, error) {
+	return self.BoundedRangePage(Between(start, end), cursor, n)
+}
+
+func (self *ordered) BoundedRangePage(bounds Bounds, cursor string, n int) (Page, error) {
+	defer self.hold()()
+	return rangePage(self.Iterator(), bounds, cursor, n)
+}
+
+func (self *ordered) PrefixPage(prefix []byte, cursor string, n int) (Page, error) {
+	defer self.hold()()
+	return prefixPage(self.Iterator(), prefix, cursor, n)
+}
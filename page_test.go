@@ -0,0 +1,154 @@
+package trie
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRangePage(t *testing.T) {
+	trie := setupTrie()
+
+	first, err := trie.RangePage([]byte("20140903"), []byte("20140910"), "", 3)
+
+	if err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if keys := kvKeys(first.Items); keys != "20140903,20140904,20140905" {
+		t.Fatalf(`Expected the first 3 keys, got %s.`, keys)
+	}
+
+	if first.Cursor == "" {
+		t.Fatalf(`Expected a cursor for the next page.`)
+	}
+
+	second, _ := trie.RangePage([]byte("20140903"), []byte("20140910"), first.Cursor, 3)
+
+	if keys := kvKeys(second.Items); keys != "20140906,20140907,20140908" {
+		t.Fatalf(`Expected the next 3 keys, got %s.`, keys)
+	}
+
+	last, _ := trie.RangePage([]byte("20140903"), []byte("20140910"), second.Cursor, 3)
+
+	if keys := kvKeys(last.Items); keys != "20140909,20140910" {
+		t.Fatalf(`Expected the last 2 keys, got %s.`, keys)
+	}
+
+	if last.Cursor != "" {
+		t.Fatalf(`Expected no cursor after the last page, got %q.`, last.Cursor)
+	}
+}
+
+func TestRangePageExactlyFull(t *testing.T) {
+	trie := setupTrie()
+	page, _ := trie.RangePage([]byte("20140901"), []byte("20140904"), "", 4)
+
+	if len(page.Items) != 4 || page.Cursor != "" {
+		t.Fatalf(`Expected 4 keys and no cursor, got %d and %q.`, len(page.Items), page.Cursor)
+	}
+
+	if page, _ := trie.RangePage([]byte("20140901"), []byte("20140904"), "", -1); len(page.Items) != 4 {
+		t.Fatalf(`Expected all 4 keys, got %d.`, len(page.Items))
+	}
+}
+
+func TestPrefixPage(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("table2#"), 0)
+	trie.Insert([]byte("table2#test1"), 1)
+	trie.Insert([]byte("table2#test10"), 2)
+	trie.Insert([]byte("table2#test2"), 3)
+	trie.Insert([]byte("table3#test1"), 4)
+
+	first, _ := trie.PrefixPage([]byte("table2"), "", 2)
+
+	if keys := kvKeys(first.Items); keys != "table2#,table2#test1" {
+		t.Fatalf(`Expected the first 2 keys, got %s.`, keys)
+	}
+
+	// Unlike OffsetPrefixN, keys that the last one starts with don't come
+	// up again.
+	second, _ := trie.PrefixPage([]byte("table2"), first.Cursor, 2)
+
+	if keys := kvKeys(second.Items); keys != "table2#test10,table2#test2" || second.Cursor != "" {
+		t.Fatalf(`Expected the last 2 keys and no cursor, got %s and %q.`, keys, second.Cursor)
+	}
+}
+
+func TestPageAfterChanges(t *testing.T) {
+	trie := setupTrie()
+	first, _ := trie.RangePage([]byte("20140901"), []byte("20140911"), "", 3)
+
+	// Take away the key the cursor is at and add some either side of it.
+	trie.Delete([]byte("20140903"))
+	trie.Insert([]byte("201409025"), "before")
+	trie.Insert([]byte("201409035"), "after")
+
+	second, err := trie.RangePage([]byte("20140901"), []byte("20140911"), first.Cursor, 2)
+
+	if err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if keys := kvKeys(second.Items); keys != "201409035,20140904" {
+		t.Fatalf(`Expected to carry on after "20140903", got %s.`, keys)
+	}
+}
+
+func TestPageBadCursor(t *testing.T) {
+	trie := setupTrie()
+
+	for _, cursor := range []string{"!!!", "AA", "Ag"} {
+		if _, err := trie.RangePage([]byte("2014"), []byte("2015"), cursor, 3); err != ErrBadCursor {
+			t.Fatalf(`Expected ErrBadCursor for %q, got %v.`, cursor, err)
+		}
+
+		if _, err := trie.PrefixPage([]byte("2014"), cursor, 3); err != ErrBadCursor {
+			t.Fatalf(`Expected ErrBadCursor for %q, got %v.`, cursor, err)
+		}
+	}
+}
+
+// Paging all the way through should find everything exactly once, no matter
+// the backend or the page size.
+func TestPageMatchesRange(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		for _, key := range generateKeys(2, "") {
+			trie.Insert([]byte(key), key)
+		}
+
+		for i := 0; i < 20; i++ {
+			n := rnd.Intn(10) + 1
+			start, end := []byte(generateKeys(1, "")[rnd.Intn(56)]), []byte("ff")
+			expected := make([]KV, 0)
+
+			for it := trie.Iterator(); it.Valid(); it.Next() {
+				if bytes.Compare(it.Key(), start) >= 0 && bytes.Compare(it.Key(), end) <= 0 {
+					expected = append(expected, KV{append([]byte{}, it.Key()...), it.Value()})
+				}
+			}
+
+			got := make([]KV, 0)
+			cursor := ""
+
+			for {
+				page, err := trie.RangePage(start, end, cursor, n)
+
+				if err != nil {
+					t.Fatalf(`Expected no error, got %v.`, err)
+				}
+
+				got = append(got, page.Items...)
+
+				if cursor = page.Cursor; cursor == "" {
+					break
+				}
+			}
+
+			if kvKeys(got) != kvKeys(expected) {
+				t.Fatalf(`Expected to page through %s, got %s.`, kvKeys(expected), kvKeys(got))
+			}
+		}
+	})
+}
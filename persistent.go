@@ -0,0 +1,131 @@
+package trie
+
+// Persistent is an immutable trie. Insert and Delete leave the trie they're
+// called on alone and hand back a new one, which shares every node that didn't
+// change with the old one. That makes holding on to an old version free, and
+// it's safe to read from any version on as many goroutines as you like while
+// someone else builds the next one.
+//
+// It's made of the same nodes as the trie New returns, so all the reads behave
+// exactly the same. The nodes in a Persistent are shared between versions,
+// which means their parent pointers can't be relied on.
+type Persistent struct {
+	Reader
+	root *trieImpl
+}
+
+// NewPersistent returns an empty Persistent trie.
+func NewPersistent() *Persistent {
+	return newPersistent(New().(*trieImpl))
+}
+
+func newPersistent(root *trieImpl) *Persistent {
+	return &Persistent{Reader: root, root: root}
+}
+
+// copy returns a shallow copy of the node with its own list of children, ready
+// to be changed.
+func (self *trieImpl) copy() *trieImpl {
+	node := *self
+	node.children = append(make([]*trieImpl, 0, len(self.children)+1), self.children...)
+	return &node
+}
+
+// with returns a copy of the node with val stored at key.
+func (self *trieImpl) with(key []byte, val interface{}, weight float64) *trieImpl {
+	node := self.copy()
+
+	if len(key) == 0 {
+		if !node.hasValue {
+			node.size += 1
+		}
+
+		node.value = val
+		node.hasValue = true
+		node.weight = weight
+		node.reweigh()
+		return node
+	}
+
+	front := key[0]
+
+	for i, child := range node.children {
+		if child.key == front {
+			updated := child.with(key[1:], val, weight)
+			node.children[i] = updated
+			node.size += updated.size - child.size
+			node.reweigh()
+			return node
+		}
+	}
+
+	// Nothing to share here, so we can build the rest the usual way.
+	node.insert(key, val, weight)
+	return node
+}
+
+// without returns a copy of the node with key removed, or the node itself if
+// key wasn't there.
+func (self *trieImpl) without(key []byte) (*trieImpl, bool) {
+	if len(key) == 0 {
+		if !self.hasValue {
+			return self, false
+		}
+
+		node := self.copy()
+		node.value = nil
+		node.hasValue = false
+		node.weight = 0
+		node.size -= 1
+		node.reweigh()
+		return node, true
+	}
+
+	front := key[0]
+
+	for i, child := range self.children {
+		if child.key == front {
+			updated, ok := child.without(key[1:])
+
+			if !ok {
+				return self, false
+			}
+
+			node := self.copy()
+			node.size -= 1
+
+			if updated.empty() {
+				node.children = append(node.children[:i], node.children[i+1:]...)
+			} else {
+				node.children[i] = updated
+			}
+
+			node.reweigh()
+			return node, true
+		}
+	}
+
+	return self, false
+}
+
+// Insert returns a new trie with val stored at key.
+func (self *Persistent) Insert(key []byte, val interface{}) *Persistent {
+	return newPersistent(self.root.with(key, val, 0))
+}
+
+// InsertWeighted returns a new trie with val stored at key, weighted for TopK.
+func (self *Persistent) InsertWeighted(key []byte, val interface{}, weight float64) *Persistent {
+	return newPersistent(self.root.with(key, val, weight))
+}
+
+// Delete returns a new trie without key in it. If key wasn't there to begin
+// with then we get the same trie back.
+func (self *Persistent) Delete(key []byte) *Persistent {
+	root, ok := self.root.without(key)
+
+	if !ok {
+		return self
+	}
+
+	return newPersistent(root)
+}
@@ -0,0 +1,114 @@
+package trie
+
+import (
+	"testing"
+)
+
+func TestPersistentInsert(t *testing.T) {
+	v1 := NewPersistent().Insert([]byte("test1"), "Hello")
+	v2 := v1.Insert([]byte("test2"), "World")
+	v3 := v2.Insert([]byte("test1"), "Again")
+
+	if v1.Len() != 1 || v2.Len() != 2 || v3.Len() != 2 {
+		t.Fatalf(`Expected lengths 1, 2 and 2, got %d, %d and %d.`, v1.Len(), v2.Len(), v3.Len())
+	}
+
+	if v1.Has([]byte("test2")) {
+		t.Fatalf(`Expected "test2" not to show up in an older version.`)
+	}
+
+	if v2.Lookup([]byte("test1")) != "Hello" {
+		t.Fatalf(`Expected "test1" to be "Hello", got %v.`, v2.Lookup([]byte("test1")))
+	}
+
+	if v3.Lookup([]byte("test1")) != "Again" {
+		t.Fatalf(`Expected "test1" to be "Again", got %v.`, v3.Lookup([]byte("test1")))
+	}
+
+	vals := v3.Range([]byte("test1"), []byte("test2"))
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+}
+
+func TestPersistentSharesNodes(t *testing.T) {
+	v1 := NewPersistent()
+	v1 = v1.Insert([]byte("table2#test1"), "Hello")
+	v1 = v1.Insert([]byte("table3#test1"), "World")
+
+	v2 := v1.Insert([]byte("table2#test2"), "Again")
+
+	// Everything under "table3" should be the very same nodes.
+	if v1.root.find([]byte("table3")) != v2.root.find([]byte("table3")) {
+		t.Fatalf(`Expected "table3" to be shared between versions.`)
+	}
+
+	if v1.root.find([]byte("table2#")) == v2.root.find([]byte("table2#")) {
+		t.Fatalf(`Expected "table2#" to have been copied.`)
+	}
+
+	if v1.root.find([]byte("table2#test1")) != v2.root.find([]byte("table2#test1")) {
+		t.Fatalf(`Expected "table2#test1" to be shared between versions.`)
+	}
+}
+
+func TestPersistentDelete(t *testing.T) {
+	v1 := NewPersistent()
+	v1 = v1.Insert([]byte("table2#test1"), "Hello")
+	v1 = v1.Insert([]byte("table2#test2"), "World")
+
+	v2 := v1.Delete([]byte("table2#test1"))
+
+	if v1.Len() != 2 || v2.Len() != 1 {
+		t.Fatalf(`Expected lengths 2 and 1, got %d and %d.`, v1.Len(), v2.Len())
+	}
+
+	if v1.Lookup([]byte("table2#test1")) != "Hello" {
+		t.Fatalf(`Expected "table2#test1" to still be in the old version.`)
+	}
+
+	if v2.Has([]byte("table2#test1")) {
+		t.Fatalf(`Expected "table2#test1" to be gone from the new version.`)
+	}
+
+	// Deleting something that isn't there shouldn't make a new version.
+	if v2.Delete([]byte("table2#test1")) != v2 {
+		t.Fatalf(`Expected deleting a missing key to return the same trie.`)
+	}
+
+	v3 := v2.Delete([]byte("table2#test2"))
+
+	if c := countNodes(v3.root); c != 1 {
+		t.Fatalf(`Expected only the root to be left, got %d nodes.`, c)
+	}
+
+	if c := countNodes(v2.root); c != 13 {
+		t.Fatalf(`Expected the old version to keep its 13 nodes, got %d.`, c)
+	}
+}
+
+func TestPersistentReadersSeeTheirVersion(t *testing.T) {
+	v1 := NewPersistent()
+
+	for i := 0; i < 100; i++ {
+		v1 = v1.Insert([]byte{'k', byte(i)}, i)
+	}
+
+	v2 := v1
+	it := v1.Iterator()
+
+	for i := 0; i < 100; i += 2 {
+		v2 = v2.Delete([]byte{'k', byte(i)})
+	}
+
+	count := 0
+
+	for ; it.Valid(); it.Next() {
+		count += 1
+	}
+
+	if count != 100 || v2.Len() != 50 {
+		t.Fatalf(`Expected to see 100 keys in the old version and 50 in the new, got %d and %d.`, count, v2.Len())
+	}
+}
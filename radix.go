@@ -0,0 +1,549 @@
+package trie
+
+import (
+	"bytes"
+	"math"
+	"sort"
+)
+
+// A radix (or Patricia) trie. Rather than spending a node on every byte, each
+// node holds the whole run of bytes that leads to it, so long keys that don't
+// share much only cost a few nodes instead of one per byte. Nodes get split up
+// on insert when a new key branches off part way through a label, and merged
+// back together on delete.
+type radixNode struct {
+	label    []byte
+	value    interface{}
+	hasValue bool
+
+	// The number of keys stored in this subtree, including this node.
+	size int
+
+	// The weight of this node's key, and the biggest weight of any key in
+	// the subtree, so TopK knows which way to go.
+	weight    float64
+	maxWeight float64
+
+	// Sorted by the first byte of their labels, which are all different.
+	children []*radixNode
+}
+
+type radixTrie struct {
+	root *radixNode
+}
+
+// NewRadix returns an empty Trie that uses path-compressed nodes. It's the same
+// as New(WithRadix()).
+func NewRadix() Trie {
+	return New(WithRadix())
+}
+
+func commonPrefix(a, b []byte) int {
+	n := minOf(len(a), len(b))
+
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+
+	return n
+}
+
+// search returns the position of the first child whose label starts with a
+// byte >= b.
+func (self *radixNode) search(b byte) int {
+	return sort.Search(len(self.children), func(i int) bool {
+		return self.children[i].label[0] >= b
+	})
+}
+
+func (self *radixNode) addChild(child *radixNode) {
+	pos := self.search(child.label[0])
+	self.children = append(self.children, nil)
+	copy(self.children[pos+1:], self.children[pos:])
+	self.children[pos] = child
+}
+
+func (self *radixNode) insert(key []byte, val interface{}, weight float64) bool {
+	if len(key) == 0 {
+		added := !self.hasValue
+		self.value = val
+		self.hasValue = true
+		self.weight = weight
+
+		if added {
+			self.size += 1
+		}
+
+		self.reweigh()
+		return added
+	}
+
+	pos := self.search(key[0])
+
+	// Nothing shares the first byte with us, so this is a brand new leaf.
+	if pos == len(self.children) || self.children[pos].label[0] != key[0] {
+		self.addChild(&radixNode{
+			label:     append([]byte{}, key...),
+			value:     val,
+			hasValue:  true,
+			size:      1,
+			weight:    weight,
+			maxWeight: weight,
+		})
+
+		self.size += 1
+		self.reweigh()
+		return true
+	}
+
+	child := self.children[pos]
+	common := commonPrefix(child.label, key)
+
+	// The key only goes part way through the child's label, so we need to
+	// split it in two and hang the rest of the child underneath.
+	if common < len(child.label) {
+		mid := &radixNode{
+			label:     child.label[:common:common],
+			children:  []*radixNode{child},
+			size:      child.size,
+			maxWeight: child.maxWeight,
+		}
+
+		child.label = child.label[common:]
+		self.children[pos] = mid
+		child = mid
+	}
+
+	added := child.insert(key[common:], val, weight)
+
+	if added {
+		self.size += 1
+	}
+
+	self.reweigh()
+	return added
+}
+
+// reweigh works out the node's maxWeight again after something underneath it
+// has changed.
+func (self *radixNode) reweigh() {
+	best := math.Inf(-1)
+
+	if self.hasValue {
+		best = self.weight
+	}
+
+	for _, child := range self.children {
+		if child.maxWeight > best {
+			best = child.maxWeight
+		}
+	}
+
+	self.maxWeight = best
+}
+
+func (self *radixTrie) Insert(key []byte, val interface{}) {
+	self.root.insert(key, val, 0)
+}
+
+func (self *radixTrie) InsertWeighted(key []byte, val interface{}, weight float64) {
+	self.root.insert(key, val, weight)
+}
+
+// child returns the child that key continues into, along with the position of
+// that child, provided the child's whole label matches.
+func (self *radixNode) child(key []byte) (*radixNode, int) {
+	pos := self.search(key[0])
+
+	if pos == len(self.children) {
+		return nil, -1
+	}
+
+	child := self.children[pos]
+
+	if !bytes.HasPrefix(key, child.label) {
+		return nil, -1
+	}
+
+	return child, pos
+}
+
+// find returns the node for key, or nil if there isn't one.
+func (self *radixTrie) find(key []byte) *radixNode {
+	node := self.root
+
+	for len(key) > 0 {
+		child, _ := node.child(key)
+
+		if child == nil {
+			return nil
+		}
+
+		key = key[len(child.label):]
+		node = child
+	}
+
+	return node
+}
+
+func (self *radixTrie) Get(key []byte) (interface{}, bool) {
+	node := self.find(key)
+
+	if node == nil {
+		return nil, false
+	}
+
+	return node.value, node.hasValue
+}
+
+// compact tidies up the child at pos after something was removed from it. Empty
+// children get dropped, and children that are just a pass-through to a single
+// grandchild get merged with it.
+func (self *radixNode) compact(pos int) {
+	child := self.children[pos]
+
+	if child.hasValue {
+		return
+	}
+
+	switch len(child.children) {
+	case 0:
+		self.children = append(self.children[:pos], self.children[pos+1:]...)
+	case 1:
+		grandchild := child.children[0]
+		label := make([]byte, 0, len(child.label)+len(grandchild.label))
+		grandchild.label = append(append(label, child.label...), grandchild.label...)
+		self.children[pos] = grandchild
+	}
+}
+
+func (self *radixNode) delete(key []byte) bool {
+	if len(key) == 0 {
+		if !self.hasValue {
+			return false
+		}
+
+		self.value = nil
+		self.hasValue = false
+		self.weight = 0
+		self.size -= 1
+		self.reweigh()
+		return true
+	}
+
+	child, pos := self.child(key)
+
+	if child == nil || !child.delete(key[len(child.label):]) {
+		return false
+	}
+
+	self.compact(pos)
+	self.size -= 1
+	self.reweigh()
+	return true
+}
+
+func (self *radixTrie) Delete(key []byte) bool {
+	return self.root.delete(key)
+}
+
+// prefixed returns the position of the child that holds every key starting
+// with prefix, or -1 if there's no such child.
+func (self *radixNode) prefixed(prefix []byte) int {
+	pos := self.search(prefix[0])
+
+	if pos == len(self.children) || !bytes.HasPrefix(self.children[pos].label, prefix) {
+		return -1
+	}
+
+	return pos
+}
+
+func (self *radixNode) deletePrefix(prefix []byte) int {
+	// The prefix ends part way through one of the children, which means
+	// the whole child goes.
+	if pos := self.prefixed(prefix); pos >= 0 {
+		removed := self.children[pos].size
+		self.children = append(self.children[:pos], self.children[pos+1:]...)
+		self.size -= removed
+		self.reweigh()
+		return removed
+	}
+
+	// Otherwise the prefix carries on past the child's label.
+	child, pos := self.child(prefix)
+
+	if child == nil {
+		return 0
+	}
+
+	removed := child.deletePrefix(prefix[len(child.label):])
+	self.compact(pos)
+	self.size -= removed
+	self.reweigh()
+	return removed
+}
+
+func (self *radixTrie) DeletePrefix(prefix []byte) int {
+	if len(prefix) == 0 {
+		removed := self.root.size
+		self.root = new(radixNode)
+		return removed
+	}
+
+	return self.root.deletePrefix(prefix)
+}
+
+func (self *radixTrie) CountPrefix(prefix []byte) int {
+	node := self.root
+
+	for len(prefix) > 0 {
+		if pos := node.prefixed(prefix); pos >= 0 {
+			return node.children[pos].size
+		}
+
+		child, _ := node.child(prefix)
+
+		if child == nil {
+			return 0
+		}
+
+		prefix = prefix[len(child.label):]
+		node = child
+	}
+
+	return node.size
+}
+
+func (self *radixTrie) Len() int {
+	return self.root.size
+}
+
+func (self *radixTrie) weigh(key []byte) float64 {
+	if node := self.find(key); node != nil {
+		return node.weight
+	}
+
+	return 0
+}
+
+func (self *radixNode) expand(visit func(child topNode, label []byte, maxWeight float64)) (interface{}, float64, bool) {
+	for _, child := range self.children {
+		visit(child, child.label, child.maxWeight)
+	}
+
+	return self.value, self.weight, self.hasValue
+}
+
+func (self *radixTrie) topK(prefix []byte, k int) []Completion {
+	return bestFirst(self.root, []byte{}, prefix, k)
+}
+
+func (self *radixTrie) Iterator() Iterator {
+	return newRadixIterator(self.root, false)
+}
+
+func (self *radixTrie) ReverseIterator() Iterator {
+	return newRadixIterator(self.root, true)
+}
+
+// radixIterator walks a radix trie the same way trieIterator walks a regular
+// one, only the key grows and shrinks a whole label at a time.
+type radixIterator struct {
+	root    *radixNode
+	reverse bool
+	path    []*radixNode
+	indexes []int
+	key     []byte
+}
+
+func newRadixIterator(root *radixNode, reverse bool) *radixIterator {
+	it := &radixIterator{root: root, reverse: reverse}
+	it.reset()
+
+	if reverse {
+		it.last()
+	}
+
+	it.settle()
+	return it
+}
+
+func (self *radixIterator) reset() {
+	self.path = append(self.path[:0], self.root)
+	self.indexes = append(self.indexes[:0], -1)
+	self.key = self.key[:0]
+}
+
+func (self *radixIterator) current() *radixNode {
+	return self.path[len(self.path)-1]
+}
+
+func (self *radixIterator) descend(i int) {
+	child := self.current().children[i]
+	self.path = append(self.path, child)
+	self.indexes = append(self.indexes, i)
+	self.key = append(self.key, child.label...)
+}
+
+// pop goes back up to the parent, returning the position of the node we left.
+func (self *radixIterator) pop() int {
+	node := self.current()
+	i := self.indexes[len(self.indexes)-1]
+
+	self.path = self.path[:len(self.path)-1]
+	self.indexes = self.indexes[:len(self.indexes)-1]
+	self.key = self.key[:len(self.key)-len(node.label)]
+
+	return i
+}
+
+func (self *radixIterator) skip() {
+	for len(self.path) > 1 {
+		i := self.pop()
+
+		if i+1 < len(self.current().children) {
+			self.descend(i + 1)
+			return
+		}
+	}
+
+	self.path = self.path[:0]
+}
+
+func (self *radixIterator) advance() {
+	if len(self.current().children) > 0 {
+		self.descend(0)
+	} else {
+		self.skip()
+	}
+}
+
+func (self *radixIterator) last() {
+	for len(self.current().children) > 0 {
+		self.descend(len(self.current().children) - 1)
+	}
+}
+
+func (self *radixIterator) retreat() {
+	if len(self.path) < 2 {
+		self.path = self.path[:0]
+		return
+	}
+
+	if i := self.pop(); i > 0 {
+		self.descend(i - 1)
+		self.last()
+	}
+}
+
+func (self *radixIterator) settle() {
+	for self.Valid() && !self.current().hasValue {
+		if self.reverse {
+			self.retreat()
+		} else {
+			self.advance()
+		}
+	}
+}
+
+func (self *radixIterator) Seek(key []byte) {
+	self.reset()
+
+	for len(key) > 0 {
+		node := self.current()
+		pos := node.search(key[0])
+
+		if self.reverse {
+			// We want the last child that starts at or before the key.
+			if pos == len(node.children) || node.children[pos].label[0] > key[0] {
+				pos -= 1
+			}
+
+			// Everything underneath comes after the key, so it's this node
+			// or whatever came before it.
+			if pos < 0 {
+				self.settle()
+				return
+			}
+		} else if pos == len(node.children) {
+			// Everything underneath this node comes before the key.
+			self.skip()
+			self.settle()
+			return
+		}
+
+		self.descend(pos)
+		label := self.current().label
+		n := minOf(len(label), len(key))
+		cmp := bytes.Compare(label[:n], key[:n])
+
+		// The label runs past the end of the key, so this subtree comes
+		// after it.
+		if cmp == 0 && len(label) > len(key) {
+			cmp = 1
+		}
+
+		if cmp < 0 {
+			// The whole subtree comes before the key.
+			if self.reverse {
+				self.last()
+			} else {
+				self.skip()
+			}
+
+			self.settle()
+			return
+		}
+
+		if cmp > 0 {
+			// The whole subtree comes after the key.
+			if self.reverse {
+				self.retreat()
+			}
+
+			self.settle()
+			return
+		}
+
+		key = key[len(label):]
+	}
+
+	self.settle()
+}
+
+func (self *radixIterator) Next() {
+	if !self.Valid() {
+		return
+	}
+
+	if self.reverse {
+		self.retreat()
+	} else {
+		self.advance()
+	}
+
+	self.settle()
+}
+
+func (self *radixIterator) Valid() bool {
+	return len(self.path) > 0
+}
+
+func (self *radixIterator) Key() []byte {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.key
+}
+
+func (self *radixIterator) Value() interface{} {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.current().value
+}
@@ -0,0 +1,148 @@
+package trie
+
+import (
+	"testing"
+)
+
+func TestRadixSuite(t *testing.T) {
+	runSuite(t, NewRadix)
+}
+
+func radixRoot(trie Trie) *radixNode {
+	return trie.(*ordered).store.(*radixTrie).root
+}
+
+func countRadixNodes(node *radixNode) int {
+	count := 1
+
+	for _, child := range node.children {
+		count += countRadixNodes(child)
+	}
+
+	return count
+}
+
+func TestRadixCompressesPaths(t *testing.T) {
+	trie := NewRadix()
+	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
+	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
+	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
+
+	// One for the root, one for "prefix1:prefix2", and then one each for
+	// ":2015-05-", "01", "30" and "00:2015-05-01".
+	if c := countRadixNodes(radixRoot(trie)); c != 6 {
+		t.Fatalf(`Expected 6 nodes, got %d.`, c)
+	}
+
+	if trie.Lookup([]byte("prefix1:prefix2")) != nil {
+		t.Fatalf(`Expected "prefix1:prefix2" not to have a value.`)
+	}
+
+	if trie.Lookup([]byte("prefix1:prefix2:2015-05-30")) != "Friend" {
+		t.Fatalf(`Expected "prefix1:prefix2:2015-05-30" to be "Friend".`)
+	}
+}
+
+func TestRadixDeleteMergesNodes(t *testing.T) {
+	trie := NewRadix()
+	trie.Insert([]byte("test"), "Hello")
+	trie.Insert([]byte("test:2015-05-01"), "World")
+	trie.Insert([]byte("test:2015-05-02"), "Again")
+
+	trie.Delete([]byte("test:2015-05-01"))
+
+	// The ":2015-05-" node should have been folded into what's left.
+	if c := countRadixNodes(radixRoot(trie)); c != 3 {
+		t.Fatalf(`Expected 3 nodes, got %d.`, c)
+	}
+
+	trie.Delete([]byte("test"))
+
+	if c := countRadixNodes(radixRoot(trie)); c != 2 {
+		t.Fatalf(`Expected 2 nodes, got %d.`, c)
+	}
+
+	if trie.Lookup([]byte("test:2015-05-02")) != "Again" {
+		t.Fatalf(`Expected "test:2015-05-02" to be "Again".`)
+	}
+
+	trie.Delete([]byte("test:2015-05-02"))
+
+	if c := countRadixNodes(radixRoot(trie)); c != 1 {
+		t.Fatalf(`Expected only the root to be left, got %d nodes.`, c)
+	}
+}
+
+func TestRadixDeletePrefix(t *testing.T) {
+	trie := NewRadix()
+	trie.Insert([]byte("table2#test1"), "Hello")
+	trie.Insert([]byte("table2#test2"), "World")
+	trie.Insert([]byte("table2"), "Yes")
+	trie.Insert([]byte("table3#test1"), "Hallooo")
+
+	if c := trie.DeletePrefix([]byte("table2#t")); c != 2 {
+		t.Fatalf(`Expected to remove 2 keys, removed %d.`, c)
+	}
+
+	if c := trie.CountPrefix([]byte("tab")); c != 2 {
+		t.Fatalf(`Expected count to be 2, got %d.`, c)
+	}
+
+	if c := trie.DeletePrefix([]byte("table")); c != 2 {
+		t.Fatalf(`Expected to remove 2 keys, removed %d.`, c)
+	}
+
+	if c := countRadixNodes(radixRoot(trie)); c != 1 {
+		t.Fatalf(`Expected only the root to be left, got %d nodes.`, c)
+	}
+}
+
+func TestRadixIteratorSeek(t *testing.T) {
+	trie := NewRadix()
+	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
+	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
+	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
+
+	it := trie.Iterator()
+	it.Seek([]byte("prefix1:prefix2:2015-05-1"))
+
+	if string(it.Key()) != "prefix1:prefix2:2015-05-30" {
+		t.Fatalf(`Expected key to be "prefix1:prefix2:2015-05-30", got "%s".`, it.Key())
+	}
+
+	// Note that "0" sorts before ":", so prefix200 comes first.
+	it.Seek([]byte("prefix1:prefix"))
+
+	if string(it.Key()) != "prefix1:prefix200:2015-05-01" {
+		t.Fatalf(`Expected key to be "prefix1:prefix200:2015-05-01", got "%s".`, it.Key())
+	}
+
+	it = trie.ReverseIterator()
+	it.Seek([]byte("prefix1:prefix2:2015-05-1"))
+
+	if string(it.Key()) != "prefix1:prefix2:2015-05-01" {
+		t.Fatalf(`Expected key to be "prefix1:prefix2:2015-05-01", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("prefix1:prefix3"))
+
+	if string(it.Key()) != "prefix1:prefix2:2015-05-30" {
+		t.Fatalf(`Expected key to be "prefix1:prefix2:2015-05-30", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("prefix1:prefix2:"))
+
+	if string(it.Key()) != "prefix1:prefix200:2015-05-01" {
+		t.Fatalf(`Expected key to be "prefix1:prefix200:2015-05-01", got "%s".`, it.Key())
+	}
+
+	it.Seek([]byte("prefix1:prefix"))
+
+	if it.Valid() {
+		t.Fatalf(`Expected iterator to be exhausted, was at "%s".`, it.Key())
+	}
+}
+
+func TestRadixMatchesTrie(t *testing.T) {
+	testMatchesTrie(t, NewRadix(), "abc")
+}
@@ -0,0 +1,243 @@
+package trie
+
+import (
+	"bytes"
+)
+
+// Rank returns the number of keys that are smaller than key. key doesn't have
+// to be in the trie.
+func (self *trieImpl) Rank(key []byte) int {
+	return self.countBelow(0, key, false)
+}
+
+// Select returns the i'th smallest key, counting from 0, so Select(Rank(key))
+// is key if it's in the trie. We skip over whole subtrees at a time by their
+// sizes, so it only takes one trip down the trie.
+func (self *trieImpl) Select(i int) ([]byte, interface{}, bool) {
+	return self.selectAt(0, i)
+}
+
+// selectAt is Select as of rev.
+func (self *trieImpl) selectAt(rev uint64, i int) ([]byte, interface{}, bool) {
+	if i < 0 || i >= self.state(rev).size {
+		return nil, nil, false
+	}
+
+	key := make([]byte, 0)
+	node := self
+
+	for {
+		if val, ok := node.at(rev); ok {
+			if i == 0 {
+				return key, val, true
+			}
+
+			i -= 1
+		}
+
+		for _, child := range node.children {
+			size := child.state(rev).size
+
+			if i < size {
+				key = append(key, child.key)
+				node = child
+				break
+			}
+
+			i -= size
+		}
+	}
+}
+
+// Snapshots of the default backend still have the sizes from back then, so
+// they can skip over subtrees too.
+func (self *trieSnapshot) rank(key []byte) int {
+	return self.root.countBelow(self.rev, key, false)
+}
+
+func (self *trieSnapshot) selectAt(i int) ([]byte, interface{}, bool) {
+	return self.root.selectAt(self.rev, i)
+}
+
+func (self *radixTrie) rank(key []byte) int {
+	count := 0
+	node := self.root
+
+	for len(key) > 0 {
+		// This node is a prefix of the key, so it comes before it.
+		if node.hasValue {
+			count += 1
+		}
+
+		pos := node.search(key[0])
+
+		for _, child := range node.children[:pos] {
+			count += child.size
+		}
+
+		if pos == len(node.children) || node.children[pos].label[0] != key[0] {
+			return count
+		}
+
+		child := node.children[pos]
+		common := commonPrefix(child.label, key)
+
+		// The key goes off somewhere in the middle of the label, so the
+		// whole child is on one side of it.
+		if common < len(child.label) {
+			if common < len(key) && child.label[common] < key[common] {
+				count += child.size
+			}
+
+			return count
+		}
+
+		key = key[common:]
+		node = child
+	}
+
+	return count
+}
+
+func (self *radixTrie) selectAt(i int) ([]byte, interface{}, bool) {
+	if i < 0 || i >= self.root.size {
+		return nil, nil, false
+	}
+
+	key := make([]byte, 0)
+	node := self.root
+
+	for {
+		if node.hasValue {
+			if i == 0 {
+				return key, node.value, true
+			}
+
+			i -= 1
+		}
+
+		for _, child := range node.children {
+			if i < child.size {
+				key = append(key, child.label...)
+				node = child
+				break
+			}
+
+			i -= child.size
+		}
+	}
+}
+
+func (self *artTrie) rank(key []byte) int {
+	count := 0
+	node := self.root
+
+	for {
+		common := commonPrefix(node.prefix, key)
+
+		// Same as for radix labels, the key goes off part way through the
+		// prefix.
+		if common < len(node.prefix) {
+			if common < len(key) && node.prefix[common] < key[common] {
+				count += node.size
+			}
+
+			return count
+		}
+
+		key = key[common:]
+
+		if len(key) == 0 {
+			return count
+		}
+
+		if node.hasValue {
+			count += 1
+		}
+
+		var next *artNode
+
+		for pos := node.lowerBound(0); pos >= 0; pos = node.after(pos) {
+			b, child := node.childAt(pos)
+
+			if b >= key[0] {
+				if b == key[0] {
+					next = child
+				}
+
+				break
+			}
+
+			count += child.size
+		}
+
+		if next == nil {
+			return count
+		}
+
+		key = key[1:]
+		node = next
+	}
+}
+
+func (self *artTrie) selectAt(i int) ([]byte, interface{}, bool) {
+	if i < 0 || i >= self.root.size {
+		return nil, nil, false
+	}
+
+	key := make([]byte, 0)
+	node := self.root
+
+	for {
+		key = append(key, node.prefix...)
+
+		if node.hasValue {
+			if i == 0 {
+				return key, node.value, true
+			}
+
+			i -= 1
+		}
+
+		for pos := node.lowerBound(0); pos >= 0; pos = node.after(pos) {
+			b, child := node.childAt(pos)
+
+			if i < child.size {
+				key = append(key, b)
+				node = child
+				break
+			}
+
+			i -= child.size
+		}
+	}
+}
+
+// The keys in a Sub are the ones in the other trie that come after the prefix
+// and before the prefix with the key on the end, so we can ask the other trie
+// for both and take the difference.
+func (self *sub) rank(key []byte) int {
+	return self.parent.Rank(self.full(key)) - self.parent.Rank(self.prefix)
+}
+
+func (self *sub) selectAt(i int) ([]byte, interface{}, bool) {
+	if i < 0 {
+		return nil, nil, false
+	}
+
+	key, val, ok := self.parent.Select(self.parent.Rank(self.prefix) + i)
+
+	if !ok || !bytes.HasPrefix(key, self.prefix) {
+		return nil, nil, false
+	}
+
+	return key[len(self.prefix):], val, true
+}
+
+func (self *ordered) Rank(key []byte) int {
+	return self.store.rank(key)
+}
+
+func (self *ordered) Select(i int) ([]byte, interface{}, bool) {
+	return self.store.selectAt(i)
+}
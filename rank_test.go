@@ -0,0 +1,211 @@
+package trie
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRankAndSelect(t *testing.T) {
+	trie := setupTrie()
+
+	if r := trie.Rank([]byte("20140905")); r != 4 {
+		t.Fatalf(`Expected rank of "20140905" to be 4, got %d.`, r)
+	}
+
+	// Keys that aren't there go where they would be.
+	if r := trie.Rank([]byte("201409055")); r != 5 {
+		t.Fatalf(`Expected rank of "201409055" to be 5, got %d.`, r)
+	}
+
+	if r := trie.Rank([]byte("2014")); r != 0 {
+		t.Fatalf(`Expected rank of "2014" to be 0, got %d.`, r)
+	}
+
+	if r := trie.Rank([]byte("3")); r != trie.Len() {
+		t.Fatalf(`Expected rank of "3" to be %d, got %d.`, trie.Len(), r)
+	}
+
+	if key, val, ok := trie.Select(4); !ok || string(key) != "20140905" || val != "20140905" {
+		t.Fatalf(`Expected key 4 to be "20140905", got %q.`, key)
+	}
+
+	if _, _, ok := trie.Select(trie.Len()); ok {
+		t.Fatalf(`Expected nothing past the end.`)
+	}
+
+	if _, _, ok := trie.Select(-1); ok {
+		t.Fatalf(`Expected nothing before the start.`)
+	}
+}
+
+// Every backend should agree with a sorted list of the keys.
+func TestRankMatchesSort(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		keys := make(map[string]bool)
+
+		for i := 0; i < 300; i++ {
+			key := randomBinaryKey(rnd)
+
+			if rnd.Intn(4) == 0 {
+				trie.Delete(key)
+				delete(keys, string(key))
+			} else {
+				trie.Insert(key, string(key))
+				keys[string(key)] = true
+			}
+		}
+
+		sorted := make([]string, 0, len(keys))
+
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+
+		sort.Strings(sorted)
+
+		for i, key := range sorted {
+			if r := trie.Rank([]byte(key)); r != i {
+				t.Fatalf(`Expected rank of %q to be %d, got %d.`, key, i, r)
+			}
+
+			if got, val, ok := trie.Select(i); !ok || string(got) != key || val != key {
+				t.Fatalf(`Expected key %d to be %q, got %q.`, i, key, got)
+			}
+		}
+
+		for i := 0; i < 100; i++ {
+			key := randomBinaryKey(rnd)
+
+			if r := trie.Rank(key); r != sort.SearchStrings(sorted, string(key)) {
+				t.Fatalf(`Expected rank of %q to be %d, got %d.`, key, sort.SearchStrings(sorted, string(key)), r)
+			}
+		}
+	})
+}
+
+// Snapshots keep deleted nodes around, which shouldn't count.
+func TestSelectWithSnapshot(t *testing.T) {
+	trie := New()
+	trie.Insert([]byte("a"), 1)
+	trie.Insert([]byte("b"), 2)
+	trie.Insert([]byte("c"), 3)
+
+	snap := trie.Snapshot()
+	defer snap.Release()
+
+	trie.Delete([]byte("b"))
+
+	if key, _, _ := trie.Select(1); string(key) != "c" {
+		t.Fatalf(`Expected key 1 to be "c", got %q.`, key)
+	}
+
+	if key, _, _ := snap.Select(1); string(key) != "b" {
+		t.Fatalf(`Expected key 1 of the snapshot to be "b", got %q.`, key)
+	}
+
+	if r := trie.Rank([]byte("c")); r != 1 {
+		t.Fatalf(`Expected rank of "c" to be 1, got %d.`, r)
+	}
+}
+
+// Snapshots and Subs of every backend should rank by sizes too, and see what
+// they're supposed to.
+func TestRankSnapshotsAndSubs(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		keys := make(map[string]bool)
+
+		for i := 0; i < 300; i++ {
+			key := randomBinaryKey(rnd)
+			trie.Insert(key, string(key))
+			keys[string(key)] = true
+		}
+
+		snap := trie.Snapshot()
+		defer snap.Release()
+
+		for i := 0; i < 100; i++ {
+			trie.Insert(randomBinaryKey(rnd), nil)
+			trie.Delete(randomBinaryKey(rnd))
+		}
+
+		sorted := make([]string, 0, len(keys))
+
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+
+		sort.Strings(sorted)
+
+		for i, key := range sorted {
+			if r := snap.Rank([]byte(key)); r != i {
+				t.Fatalf(`Expected rank of %q in the snapshot to be %d, got %d.`, key, i, r)
+			}
+
+			if got, val, ok := snap.Select(i); !ok || string(got) != key || val != key {
+				t.Fatalf(`Expected key %d of the snapshot to be %q, got %q.`, i, key, got)
+			}
+		}
+
+		if _, _, ok := snap.Select(len(sorted)); ok {
+			t.Fatalf(`Expected nothing past the end of the snapshot.`)
+		}
+
+		prefix := []byte(sorted[len(sorted)-1][:1])
+		sub := trie.Sub(prefix)
+		subKeys := make([]string, 0)
+
+		for it := trie.Iterator(); it.Valid(); it.Next() {
+			if bytes.HasPrefix(it.Key(), prefix) {
+				subKeys = append(subKeys, string(it.Key()[len(prefix):]))
+			}
+		}
+
+		for i, key := range subKeys {
+			if r := sub.Rank([]byte(key)); r != i {
+				t.Fatalf(`Expected rank of %q in the Sub to be %d, got %d.`, key, i, r)
+			}
+
+			if got, _, ok := sub.Select(i); !ok || string(got) != key {
+				t.Fatalf(`Expected key %d of the Sub to be %q, got %q.`, i, key, got)
+			}
+		}
+
+		if _, _, ok := sub.Select(len(subKeys)); ok {
+			t.Fatalf(`Expected nothing past the end of the Sub.`)
+		}
+	})
+}
+
+// Nodes with lots of children are stored differently in ART, so Rank and
+// Select have to find their way around those too.
+func TestRankWideNodes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trie := NewART()
+	keys := make(map[string]bool)
+
+	for i := 0; i < 2000; i++ {
+		key := []byte{byte(rnd.Intn(256)), byte(rnd.Intn(256))}[:rnd.Intn(2)+1]
+		trie.Insert(key, nil)
+		keys[string(key)] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+
+	sort.Strings(sorted)
+
+	for i, key := range sorted {
+		if r := trie.Rank([]byte(key)); r != i {
+			t.Fatalf(`Expected rank of %q to be %d, got %d.`, key, i, r)
+		}
+
+		if got, _, _ := trie.Select(i); string(got) != key {
+			t.Fatalf(`Expected key %d to be %q, got %q.`, i, key, got)
+		}
+	}
+}
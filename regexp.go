@@ -0,0 +1,181 @@
+package trie
+
+import (
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// regexpState is where the program is up to after some number of bytes of a
+// key. Keys get decoded into runes the same way the regexp package does it,
+// with anything that isn't valid UTF-8 turning into utf8.RuneError a byte at
+// a time.
+type regexpState struct {
+	// The instructions we got to by consuming the last rune. We don't
+	// follow them any further until we know what the next rune is, since
+	// things like \b depend on it.
+	pcs []uint32
+
+	// The start of a rune we haven't seen all of yet.
+	pending []byte
+
+	// The last rune we consumed, or -1 at the start.
+	prev rune
+}
+
+// regexpMatcher runs a compiled regular expression over the trie as an NFA,
+// keeping track of every instruction we could be at. Once there aren't any
+// left, nothing further down can match.
+type regexpMatcher struct {
+	prog   *syntax.Prog
+	states []regexpState
+	depth  int
+
+	// Scratch space for working out closures.
+	seen      []bool
+	stack     []uint32
+	consumers []uint32
+}
+
+func newRegexpMatcher(prog *syntax.Prog) *regexpMatcher {
+	m := &regexpMatcher{prog: prog, seen: make([]bool, len(prog.Inst))}
+	m.states = []regexpState{{pcs: []uint32{uint32(prog.Start)}, prev: -1}}
+	return m
+}
+
+// closure follows everything that doesn't consume a rune from pcs, given the
+// runes either side of where we are (-1 for the start or the end of the key).
+// It returns the instructions that consume a rune, and whether we could match
+// right here.
+func (self *regexpMatcher) closure(pcs []uint32, prev, next rune, out []uint32) ([]uint32, bool) {
+	ctx := syntax.EmptyOpContext(prev, next)
+	matched := false
+
+	for i := range self.seen {
+		self.seen[i] = false
+	}
+
+	self.stack = append(self.stack[:0], pcs...)
+
+	for len(self.stack) > 0 {
+		pc := self.stack[len(self.stack)-1]
+		self.stack = self.stack[:len(self.stack)-1]
+
+		if self.seen[pc] {
+			continue
+		}
+
+		self.seen[pc] = true
+		inst := &self.prog.Inst[pc]
+
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			self.stack = append(self.stack, inst.Arg, inst.Out)
+		case syntax.InstCapture, syntax.InstNop:
+			self.stack = append(self.stack, inst.Out)
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(inst.Arg)&^ctx == 0 {
+				self.stack = append(self.stack, inst.Out)
+			}
+		case syntax.InstMatch:
+			matched = true
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			out = append(out, pc)
+		}
+	}
+
+	return out, matched
+}
+
+// step consumes r, returning where that leaves us.
+func (self *regexpMatcher) step(pcs []uint32, prev, r rune) []uint32 {
+	self.consumers, _ = self.closure(pcs, prev, r, self.consumers[:0])
+	next := pcs[:0]
+
+	for _, pc := range self.consumers {
+		inst := &self.prog.Inst[pc]
+
+		if inst.MatchRune(r) {
+			next = append(next, inst.Out)
+		}
+	}
+
+	return next
+}
+
+func (self *regexpMatcher) push(b byte) bool {
+	self.depth += 1
+
+	if self.depth == len(self.states) {
+		self.states = append(self.states, regexpState{})
+	}
+
+	prev, state := &self.states[self.depth-1], &self.states[self.depth]
+	state.pcs = append(state.pcs[:0], prev.pcs...)
+	state.pending = append(append(state.pending[:0], prev.pending...), b)
+	state.prev = prev.prev
+
+	for len(state.pending) > 0 && utf8.FullRune(state.pending) {
+		r, size := utf8.DecodeRune(state.pending)
+		state.pcs = self.step(state.pcs, state.prev, r)
+		state.prev = r
+		state.pending = state.pending[:copy(state.pending, state.pending[size:])]
+	}
+
+	return len(state.pcs) > 0
+}
+
+func (self *regexpMatcher) pop() {
+	self.depth -= 1
+}
+
+func (self *regexpMatcher) matches() bool {
+	state := &self.states[self.depth]
+	pcs := append([]uint32{}, state.pcs...)
+	prev := state.prev
+
+	// Whatever's left over is never going to be a whole rune.
+	for range state.pending {
+		pcs = self.step(pcs, prev, utf8.RuneError)
+		prev = utf8.RuneError
+	}
+
+	_, matched := self.closure(pcs, prev, -1, nil)
+	return matched
+}
+
+func regexpSearch(s searcher, re *syntax.Regexp, n int) (map[string]interface{}, error) {
+	prog, err := syntax.Compile(re.Simplify())
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]interface{})
+
+	if n == 0 {
+		return res, nil
+	}
+
+	s.search(newRegexpMatcher(prog), func(key []byte, val interface{}) bool {
+		res[string(key)] = val
+		return len(res) != n
+	})
+
+	return res, nil
+}
+
+// Regexp returns up to n keys (or all of them if n is negative) that match re
+// from start to finish, as if it was wrapped in ^(?:...)$. Get one from
+// syntax.Parse:
+//
+//	re, err := syntax.Parse(`2014-0[1-3]-\d+`, syntax.Perl)
+//
+// Parts of the trie that can't possibly match get skipped over, so the more
+// specific the start of the expression is, the less of the trie we look at.
+func (self *trieImpl) Regexp(re *syntax.Regexp, n int) (map[string]interface{}, error) {
+	return regexpSearch(self, re, n)
+}
+
+func (self *ordered) Regexp(re *syntax.Regexp, n int) (map[string]interface{}, error) {
+	return regexpSearch(self, re, n)
+}
@@ -0,0 +1,133 @@
+package trie
+
+import (
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+)
+
+func parseRegexp(t *testing.T, expr string) *syntax.Regexp {
+	re, err := syntax.Parse(expr, syntax.Perl)
+
+	if err != nil {
+		t.Fatalf(`Expected %q to parse, got %v.`, expr, err)
+	}
+
+	return re
+}
+
+func TestRegexp(t *testing.T) {
+	trie := setupTrie()
+
+	vals, err := trie.Regexp(parseRegexp(t, `2014090[1-5]`), -1)
+
+	if err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if len(vals) != 5 {
+		t.Fatalf(`Expected length of vals to be 5, got %d.`, len(vals))
+	}
+
+	if vals["20140905"] != "20140905" {
+		t.Fatalf(`Expected "20140905" to be "20140905", got %v.`, vals["20140905"])
+	}
+
+	// The whole key has to match.
+	if vals, _ := trie.Regexp(parseRegexp(t, `201409`), -1); len(vals) != 0 {
+		t.Fatalf(`Expected nothing to match, got %v.`, vals)
+	}
+
+	if vals, _ := trie.Regexp(parseRegexp(t, `\d+1`), -1); len(vals) != 2 {
+		t.Fatalf(`Expected length of vals to be 2, got %d.`, len(vals))
+	}
+}
+
+func TestRegexpN(t *testing.T) {
+	trie := setupTrie()
+	vals, _ := trie.Regexp(parseRegexp(t, `2014.*`), 3)
+
+	if len(vals) != 3 {
+		t.Fatalf(`Expected length of vals to be 3, got %d.`, len(vals))
+	}
+
+	// They come in order, so it's the first three.
+	if vals["20140903"] != "20140903" {
+		t.Fatalf(`Expected "20140903" to be in the results, got %v.`, vals)
+	}
+
+	if vals, _ := trie.Regexp(parseRegexp(t, `2014.*`), 0); len(vals) != 0 {
+		t.Fatalf(`Expected length of vals to be 0, got %d.`, len(vals))
+	}
+}
+
+func TestRegexpPrunes(t *testing.T) {
+	trie := New().(*trieImpl)
+
+	for _, key := range generateKeys(4, "") {
+		trie.Insert([]byte(key), key)
+	}
+
+	prog, _ := syntax.Compile(parseRegexp(t, `ab[c-d]e?.*`))
+	m := &countingMatcher{matcher: newRegexpMatcher(prog)}
+	trie.search(m, func(key []byte, val interface{}) bool {
+		return true
+	})
+
+	if nodes := countNodes(trie); m.pushes >= nodes/10 {
+		t.Fatalf(`Expected to look at a small part of the %d nodes, looked at %d.`, nodes, m.pushes)
+	}
+}
+
+// Check against the regexp package, with keys that are a mix of ASCII,
+// multibyte runes and bytes that aren't valid UTF-8 at all.
+func TestRegexpMatchesRegexpPackage(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		pieces := []string{"a", "b", " ", "é", "\xe2\x82", "\xff"}
+		exprs := []string{
+			`a*`, `(a|b)+`, `.*`, `a.b`, `[^a]*`, `\bab?\b.*`, `(?i)A+B`, `é+.?`,
+			`\x{FFFD}.*`, `a(?:b|é)*`, `.b$`, `^a.*`, `(?s:.)*\B`, `\w+ \w+`, ``,
+		}
+		keys := make([]string, 0)
+
+		for i := 0; i < 400; i++ {
+			key := ""
+
+			for j := rnd.Intn(5); j > 0; j-- {
+				key += pieces[rnd.Intn(len(pieces))]
+			}
+
+			keys = append(keys, key)
+		}
+
+		for _, key := range keys {
+			trie.Insert([]byte(key), key)
+		}
+
+		for _, expr := range exprs {
+			vals, err := trie.Regexp(parseRegexp(t, expr), -1)
+
+			if err != nil {
+				t.Fatalf(`Expected no error for %q, got %v.`, expr, err)
+			}
+
+			re := regexp.MustCompile(`^(?:` + expr + `)$`)
+			expected := 0
+
+			for it := trie.Iterator(); it.Valid(); it.Next() {
+				if re.Match(it.Key()) {
+					expected += 1
+
+					if _, ok := vals[string(it.Key())]; !ok {
+						t.Fatalf(`Expected %q to match %q.`, it.Key(), expr)
+					}
+				}
+			}
+
+			if len(vals) != expected {
+				t.Fatalf(`Expected %d matches for %q, got %d.`, expected, expr, len(vals))
+			}
+		}
+	})
+}
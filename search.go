@@ -0,0 +1,90 @@
+package trie
+
+// A matcher gets fed keys one byte at a time as we walk down the trie, and
+// tells us when there's no point going any further. That lets searches like
+// Fuzzy skip whole subtrees instead of looking at every key.
+type matcher interface {
+	// push moves down to the next byte, returning false if no key that
+	// starts with what's been pushed so far can match.
+	push(b byte) bool
+
+	// pop undoes the last push, whether or not it returned true.
+	pop()
+
+	// matches reports whether what's been pushed so far is a match on its
+	// own.
+	matches() bool
+}
+
+// searcher is anything that can run a matcher over its keys, handing every
+// match to emit in order until emit returns false.
+type searcher interface {
+	search(m matcher, emit func(key []byte, val interface{}) bool)
+}
+
+func (self *trieImpl) search(m matcher, emit func(key []byte, val interface{}) bool) {
+	self.searchFrom(m, []byte{}, emit)
+}
+
+func (self *trieImpl) searchFrom(m matcher, key []byte, emit func(key []byte, val interface{}) bool) bool {
+	if self.hasValue && m.matches() && !emit(key, self.value) {
+		return false
+	}
+
+	for _, child := range self.children {
+		ok := !m.push(child.key) || child.searchFrom(m, append(key, child.key), emit)
+		m.pop()
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Other backends get searched with an iterator. We push the bytes of each key
+// that aren't shared with the last one, and whenever the matcher gives up
+// part way through a key we seek past everything else that starts the same
+// way.
+func (self *ordered) search(m matcher, emit func(key []byte, val interface{}) bool) {
+	defer self.hold()()
+
+	it := self.Iterator()
+	path := make([]byte, 0)
+
+	for it.Valid() {
+		key := it.Key()
+
+		for n := commonPrefix(path, key); len(path) > n; {
+			path = path[:len(path)-1]
+			m.pop()
+		}
+
+		dead := false
+
+		for len(path) < len(key) && !dead {
+			path = append(path, key[len(path)])
+			dead = !m.push(path[len(path)-1])
+		}
+
+		if dead {
+			end := prefixEnd(path)
+			path = path[:len(path)-1]
+			m.pop()
+
+			if end == nil {
+				return
+			}
+
+			it.Seek(end)
+			continue
+		}
+
+		if m.matches() && !emit(key, it.Value()) {
+			return
+		}
+
+		it.Next()
+	}
+}
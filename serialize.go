@@ -0,0 +1,557 @@
+package trie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Saved tries look like this:
+//
+//	magic    "TRIE"
+//	version  1 byte
+//	layout   1 byte, one of the layouts below
+//	codec    uvarint length, then the codec's name
+//	body     depends on the layout
+//	checksum CRC-32 (IEEE) of everything above, 4 bytes big endian
+//
+// The nodes layout is what the default backend writes. It's the node
+// structure, depth first:
+//
+//	flags    1 byte, flagValue is set if a key ends here, and flagWeight if
+//	         it has a weight other than 0
+//	value    uvarint length, then the encoded value (only if flagValue)
+//	weight   float64, 8 bytes big endian (only if flagWeight)
+//	children uvarint count, then for each child its key byte and then the
+//	         child itself
+//
+// The entries layout is what everything else writes. It's just the keys and
+// values in order:
+//
+//	count    uvarint
+//	entries  uvarint key length, key, flags (only flagWeight), uvarint value
+//	         length, encoded value, then the weight if flagWeight is set
+//
+// Either layout can be read into any backend. Version 1 was the same, only
+// without any weights or the flags on entries, and we can still read it.
+const (
+	formatMagic   = "TRIE"
+	formatVersion = 2
+
+	layoutNodes   = 0
+	layoutEntries = 1
+
+	flagValue  = 1 << 0
+	flagWeight = 1 << 1
+)
+
+var (
+	// ErrBadFormat is returned by ReadFrom when what it's reading isn't a saved
+	// trie, or is cut off partway through.
+	ErrBadFormat = errors.New("trie: malformed saved trie")
+
+	// ErrUnsupportedVersion is returned by ReadFrom for a format version it
+	// doesn't know, usually a trie saved by a newer version of this package.
+	ErrUnsupportedVersion = errors.New("trie: unsupported format version")
+
+	// ErrChecksum is returned by ReadFrom when the checksum at the end doesn't
+	// match what it read.
+	ErrChecksum = errors.New("trie: checksum mismatch")
+
+	// ErrCodecMismatch is returned by ReadFrom when the trie was saved with a
+	// different Codec than the one it's being read with.
+	ErrCodecMismatch = errors.New("trie: saved with a different codec")
+)
+
+// encoder keeps track of how much we've written and what the checksum is so
+// far. The first error sticks, and everything after it is a no-op.
+type encoder struct {
+	w     *bufio.Writer
+	crc   hash.Hash32
+	codec Codec
+	n     int64
+	err   error
+	buf   [binary.MaxVarintLen64]byte
+}
+
+func newEncoder(w io.Writer, codec Codec, layout byte) *encoder {
+	enc := &encoder{w: bufio.NewWriter(w), crc: crc32.NewIEEE(), codec: codec}
+	enc.write([]byte(formatMagic))
+	enc.write([]byte{formatVersion, layout})
+	enc.writeBytes([]byte(codec.Name()))
+	return enc
+}
+
+func (self *encoder) write(p []byte) {
+	if self.err != nil {
+		return
+	}
+
+	n, err := self.w.Write(p)
+	self.n += int64(n)
+	self.err = err
+	self.crc.Write(p[:n])
+}
+
+func (self *encoder) writeUvarint(v uint64) {
+	n := binary.PutUvarint(self.buf[:], v)
+	self.write(self.buf[:n])
+}
+
+func (self *encoder) writeBytes(p []byte) {
+	self.writeUvarint(uint64(len(p)))
+	self.write(p)
+}
+
+func (self *encoder) writeValue(val interface{}) {
+	if self.err != nil {
+		return
+	}
+
+	data, err := self.codec.Marshal(val)
+
+	if err != nil {
+		self.err = err
+		return
+	}
+
+	self.writeBytes(data)
+}
+
+func (self *encoder) writeWeight(weight float64) {
+	binary.BigEndian.PutUint64(self.buf[:8], math.Float64bits(weight))
+	self.write(self.buf[:8])
+}
+
+// writeWeighted writes flags, with flagWeight added if it's needed, then the
+// value and the weight.
+func (self *encoder) writeWeighted(flags byte, val interface{}, weight float64) {
+	if weight != 0 {
+		flags |= flagWeight
+	}
+
+	self.write([]byte{flags})
+	self.writeValue(val)
+
+	if weight != 0 {
+		self.writeWeight(weight)
+	}
+}
+
+func (self *encoder) writeNode(node *trieImpl) {
+	if node.hasValue {
+		self.writeWeighted(flagValue, node.value, node.weight)
+	} else {
+		self.write([]byte{0})
+	}
+
+	self.writeUvarint(uint64(len(node.children)))
+
+	for _, child := range node.children {
+		self.write([]byte{child.key})
+		self.writeNode(child)
+	}
+}
+
+// finish writes out the checksum and flushes everything through.
+func (self *encoder) finish() (int64, error) {
+	self.write(self.crc.Sum(nil))
+
+	if self.err == nil {
+		self.err = self.w.Flush()
+	}
+
+	return self.n, self.err
+}
+
+type decoder struct {
+	r       *bufio.Reader
+	crc     hash.Hash32
+	codec   Codec
+	n       int64
+	version byte
+
+	// The last error we got from r, so that we can tell it apart from
+	// garbage in the data when decoding varints.
+	err error
+}
+
+// newDecoder reads the header, returning the layout of the body.
+func newDecoder(r io.Reader, codec Codec) (*decoder, byte, error) {
+	dec := &decoder{r: bufio.NewReader(r), crc: crc32.NewIEEE(), codec: codec}
+	header := make([]byte, len(formatMagic)+2)
+
+	if err := dec.read(header); err != nil {
+		return dec, 0, err
+	}
+
+	if string(header[:len(formatMagic)]) != formatMagic {
+		return dec, 0, ErrBadFormat
+	}
+
+	dec.version = header[len(formatMagic)]
+
+	if dec.version < 1 || dec.version > formatVersion {
+		return dec, 0, ErrUnsupportedVersion
+	}
+
+	name, err := dec.readBytes()
+
+	if err != nil {
+		return dec, 0, err
+	}
+
+	if string(name) != codec.Name() {
+		return dec, 0, ErrCodecMismatch
+	}
+
+	layout := header[len(formatMagic)+1]
+
+	if layout != layoutNodes && layout != layoutEntries {
+		return dec, 0, ErrBadFormat
+	}
+
+	return dec, layout, nil
+}
+
+func (self *decoder) read(p []byte) error {
+	n, err := io.ReadFull(self.r, p)
+	self.n += int64(n)
+	self.crc.Write(p[:n])
+
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+func (self *decoder) ReadByte() (byte, error) {
+	b, err := self.r.ReadByte()
+
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		self.err = err
+		return 0, err
+	}
+
+	self.n += 1
+	self.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (self *decoder) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(self)
+
+	if err != nil {
+		if self.err != nil {
+			return 0, self.err
+		}
+
+		// It overflowed.
+		return 0, ErrBadFormat
+	}
+
+	return v, nil
+}
+
+func (self *decoder) readBytes() ([]byte, error) {
+	n, err := self.readUvarint()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't trust the length enough to allocate it all up front, it could
+	// be garbage.
+	data, err := io.ReadAll(io.LimitReader(self.r, int64(n)))
+	self.n += int64(len(data))
+	self.crc.Write(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(data)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return data, nil
+}
+
+func (self *decoder) readValue() (interface{}, error) {
+	data, err := self.readBytes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return self.codec.Unmarshal(data)
+}
+
+// readFlags reads a flags byte, making sure nothing but what's allowed is set.
+// Version 1 didn't have weights.
+func (self *decoder) readFlags(allowed byte) (byte, error) {
+	flags, err := self.ReadByte()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if self.version < 2 {
+		allowed &^= flagWeight
+	}
+
+	if flags&^allowed != 0 {
+		return 0, ErrBadFormat
+	}
+
+	return flags, nil
+}
+
+// readWeighted reads the value and the weight that go with flags.
+func (self *decoder) readWeighted(flags byte) (interface{}, float64, error) {
+	val, err := self.readValue()
+
+	if err != nil || flags&flagWeight == 0 {
+		return val, 0, err
+	}
+
+	buf := make([]byte, 8)
+
+	if err := self.read(buf); err != nil {
+		return nil, 0, err
+	}
+
+	return val, math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+}
+
+// readNode reads a node and everything under it, handing each key, value and
+// weight to emit.
+func (self *decoder) readNode(key []byte, emit func(key []byte, val interface{}, weight float64)) error {
+	flags, err := self.readFlags(flagValue | flagWeight)
+
+	if err != nil {
+		return err
+	}
+
+	// Only keys have weights.
+	if flags == flagWeight {
+		return ErrBadFormat
+	}
+
+	if flags&flagValue != 0 {
+		val, weight, err := self.readWeighted(flags)
+
+		if err != nil {
+			return err
+		}
+
+		emit(key, val, weight)
+	}
+
+	count, err := self.readUvarint()
+
+	if err != nil {
+		return err
+	}
+
+	if count > 256 {
+		return ErrBadFormat
+	}
+
+	prev := -1
+
+	for i := uint64(0); i < count; i++ {
+		b, err := self.ReadByte()
+
+		if err != nil {
+			return err
+		}
+
+		// Children are always written in order.
+		if int(b) <= prev {
+			return ErrBadFormat
+		}
+
+		prev = int(b)
+
+		if err := self.readNode(append(key, b), emit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *decoder) readEntries(emit func(key []byte, val interface{}, weight float64)) error {
+	count, err := self.readUvarint()
+
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		key, err := self.readBytes()
+
+		if err != nil {
+			return err
+		}
+
+		var flags byte
+
+		if self.version >= 2 {
+			if flags, err = self.readFlags(flagWeight); err != nil {
+				return err
+			}
+		}
+
+		val, weight, err := self.readWeighted(flags)
+
+		if err != nil {
+			return err
+		}
+
+		emit(key, val, weight)
+	}
+
+	return nil
+}
+
+// load reads a saved trie from r, handing every key, value and weight to emit.
+func load(r io.Reader, codec Codec, emit func(key []byte, val interface{}, weight float64)) (int64, error) {
+	dec, layout, err := newDecoder(r, codec)
+
+	if err != nil {
+		return dec.n, err
+	}
+
+	if layout == layoutNodes {
+		err = dec.readNode([]byte{}, emit)
+	} else {
+		err = dec.readEntries(emit)
+	}
+
+	if err != nil {
+		return dec.n, err
+	}
+
+	expected := dec.crc.Sum32()
+	checksum := make([]byte, 4)
+
+	if err := dec.read(checksum); err != nil {
+		return dec.n, err
+	}
+
+	if binary.BigEndian.Uint32(checksum) != expected {
+		return dec.n, ErrChecksum
+	}
+
+	return dec.n, nil
+}
+
+// WriteTo saves the trie to w, node by node, encoding the values with the
+// trie's codec.
+func (self *trieImpl) WriteTo(w io.Writer) (int64, error) {
+	enc := newEncoder(w, self.opts.codec, layoutNodes)
+	enc.writeNode(self)
+	return enc.finish()
+}
+
+// ReadFrom replaces the contents of the trie with a trie saved by WriteTo. If
+// anything goes wrong the trie is left as it was.
+func (self *trieImpl) ReadFrom(r io.Reader) (int64, error) {
+	fresh := new(trieImpl)
+
+	n, err := load(r, self.opts.codec, func(key []byte, val interface{}, weight float64) {
+		fresh.insert(key, val, weight)
+	})
+
+	if err != nil {
+		return n, err
+	}
+
+	// Snapshots still need the old nodes, so the new contents have to go in
+	// one key at a time.
+	if self.versions.active() {
+		deleteKeys(self.Iterator(), self.Delete, func(key []byte) bool {
+			return true
+		})
+
+		for it := fresh.Iterator(); it.Valid(); it.Next() {
+			self.InsertWeighted(it.Key(), it.Value(), fresh.weigh(it.Key()))
+		}
+
+		return n, nil
+	}
+
+	self.clear()
+	self.value = fresh.value
+	self.hasValue = fresh.hasValue
+	self.children = fresh.children
+	self.size = fresh.size
+	self.maxWeight = fresh.maxWeight
+
+	for _, child := range self.children {
+		child.parent = self
+	}
+
+	return n, nil
+}
+
+// WriteTo saves the trie to w as a list of keys and values, encoding the values
+// with the trie's codec.
+func (self *ordered) WriteTo(w io.Writer) (int64, error) {
+	// The count goes first, so we collect the entries up before writing
+	// anything. Tries that can be written to while we're reading them might
+	// not have as many keys as Len said by the time we get to the end.
+	entries := make([]Completion, 0)
+	release := self.hold()
+
+	for it := self.Iterator(); it.Valid(); it.Next() {
+		entries = append(entries, Completion{append([]byte{}, it.Key()...), it.Value(), self.weigh(it.Key())})
+	}
+
+	release()
+
+	enc := newEncoder(w, self.opts.codec, layoutEntries)
+	enc.writeUvarint(uint64(len(entries)))
+
+	for _, entry := range entries {
+		enc.writeBytes(entry.Key)
+		enc.writeWeighted(0, entry.Value, entry.Weight)
+	}
+
+	return enc.finish()
+}
+
+// ReadFrom replaces the contents of the trie with a trie saved by WriteTo. If
+// anything goes wrong the trie is left as it was.
+func (self *ordered) ReadFrom(r io.Reader) (int64, error) {
+	fresh := self.opts.backend()
+
+	n, err := load(r, self.opts.codec, func(key []byte, val interface{}, weight float64) {
+		fresh.InsertWeighted(key, val, weight)
+	})
+
+	if err != nil {
+		return n, err
+	}
+
+	// Concurrent tries can't have the store swapped out from under whoever
+	// else is using them, so they take the new contents over themselves.
+	if s, ok := self.store.(swapper); ok {
+		s.swap(fresh)
+	} else {
+		self.store = fresh
+	}
+
+	return n, nil
+}
@@ -0,0 +1,234 @@
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	backends := map[string]func(...Option) Trie{
+		"trie": New,
+		"radix": func(opts ...Option) Trie {
+			return New(append(opts, WithRadix())...)
+		},
+		"art": func(opts ...Option) Trie {
+			return New(append(opts, WithART())...)
+		},
+	}
+
+	for name, newTrie := range backends {
+		for _, codec := range []Codec{GobCodec{}, JSONCodec{}} {
+			trie := newTrie(WithCodec(codec))
+			trie.Insert([]byte(""), "Empty")
+			trie.Insert([]byte("test"), "Hello")
+			trie.Insert([]byte("test2"), "World")
+			trie.Insert([]byte("table2#test1"), nil)
+
+			var buf bytes.Buffer
+			n, err := trie.WriteTo(&buf)
+
+			if err != nil {
+				t.Fatalf(`%s/%s: Expected to save, got %v.`, name, codec.Name(), err)
+			}
+
+			if n != int64(buf.Len()) {
+				t.Fatalf(`%s/%s: Expected to have written %d bytes, said %d.`, name, codec.Name(), buf.Len(), n)
+			}
+
+			loaded := newTrie(WithCodec(codec))
+			loaded.Insert([]byte("stale"), "Gone")
+
+			if _, err := loaded.ReadFrom(&buf); err != nil {
+				t.Fatalf(`%s/%s: Expected to load, got %v.`, name, codec.Name(), err)
+			}
+
+			if loaded.Len() != 4 {
+				t.Fatalf(`%s/%s: Expected length to be 4, got %d.`, name, codec.Name(), loaded.Len())
+			}
+
+			if loaded.Lookup([]byte("test2")) != "World" || loaded.Lookup([]byte("")) != "Empty" {
+				t.Fatalf(`%s/%s: Expected values to survive, got %v.`, name, codec.Name(), loaded.Prefix(nil))
+			}
+
+			if val, ok := loaded.Get([]byte("table2#test1")); !ok || val != nil {
+				t.Fatalf(`%s/%s: Expected a nil value to survive.`, name, codec.Name())
+			}
+
+			if loaded.Has([]byte("stale")) {
+				t.Fatalf(`%s/%s: Expected loading to replace what was there.`, name, codec.Name())
+			}
+		}
+	}
+}
+
+func TestSerializeAcrossBackends(t *testing.T) {
+	trie := setupTrie()
+
+	var buf bytes.Buffer
+	trie.WriteTo(&buf)
+
+	loaded := NewART()
+
+	if _, err := loaded.ReadFrom(&buf); err != nil {
+		t.Fatalf(`Expected to load, got %v.`, err)
+	}
+
+	if loaded.Len() != 11 {
+		t.Fatalf(`Expected length to be 11, got %d.`, loaded.Len())
+	}
+
+	trie = NewRadix()
+	trie.Insert([]byte("table2#test1"), "Hello")
+	trie.WriteTo(&buf)
+
+	loaded = New()
+
+	if _, err := loaded.ReadFrom(&buf); err != nil {
+		t.Fatalf(`Expected to load, got %v.`, err)
+	}
+
+	if loaded.Lookup([]byte("table2#test1")) != "Hello" {
+		t.Fatalf(`Expected "table2#test1" to be "Hello".`)
+	}
+}
+
+func TestSerializeBytesCodec(t *testing.T) {
+	trie := New(WithCodec(BytesCodec{}))
+	trie.Insert([]byte("test1"), []byte("Hello"))
+
+	var buf bytes.Buffer
+
+	if _, err := trie.WriteTo(&buf); err != nil {
+		t.Fatalf(`Expected to save, got %v.`, err)
+	}
+
+	trie.Insert([]byte("test2"), 42)
+
+	if _, err := trie.WriteTo(io.Discard); err == nil {
+		t.Fatalf(`Expected saving an int with BytesCodec to fail.`)
+	}
+}
+
+func TestSerializeErrors(t *testing.T) {
+	trie := setupTrie()
+
+	var buf bytes.Buffer
+	trie.WriteTo(&buf)
+	saved := buf.Bytes()
+
+	loaded := New()
+	loaded.Insert([]byte("test"), "Hello")
+
+	corrupt := append([]byte{}, saved...)
+	corrupt[len(corrupt)/2] ^= 0xFF
+
+	if _, err := loaded.ReadFrom(bytes.NewReader(corrupt)); err != ErrChecksum && err != ErrBadFormat {
+		t.Fatalf(`Expected a corrupt trie to fail, got %v.`, err)
+	}
+
+	if _, err := loaded.ReadFrom(bytes.NewReader(saved[:len(saved)-6])); err != io.ErrUnexpectedEOF {
+		t.Fatalf(`Expected a truncated trie to fail, got %v.`, err)
+	}
+
+	if _, err := loaded.ReadFrom(bytes.NewReader([]byte("not a trie at all"))); err != ErrBadFormat {
+		t.Fatalf(`Expected garbage to fail, got %v.`, err)
+	}
+
+	version := append([]byte{}, saved...)
+	version[len(formatMagic)] = formatVersion + 1
+
+	if _, err := loaded.ReadFrom(bytes.NewReader(version)); err != ErrUnsupportedVersion {
+		t.Fatalf(`Expected a newer version to fail, got %v.`, err)
+	}
+
+	if _, err := New(WithCodec(JSONCodec{})).ReadFrom(bytes.NewReader(saved)); err != ErrCodecMismatch {
+		t.Fatalf(`Expected loading with the wrong codec to fail, got %v.`, err)
+	}
+
+	// None of that should have touched what was there.
+	if loaded.Len() != 1 || loaded.Lookup([]byte("test")) != "Hello" {
+		t.Fatalf(`Expected a failed load to leave the trie alone.`)
+	}
+}
+
+func sameCompletions(a, b []Completion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i].Key, b[i].Key) || a[i].Value != b[i].Value || a[i].Weight != b[i].Weight {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSerializeWeights(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		for _, key := range generateKeys(2, "") {
+			trie.InsertWeighted([]byte(key), key, float64(rnd.Intn(100)-50))
+		}
+
+		trie.Insert([]byte("abc"), "abc")
+		expected := trie.TopK([]byte{}, -1)
+
+		// Both layouts should bring the weights back, whatever they're
+		// loaded into.
+		for _, b := range backends {
+			var buf bytes.Buffer
+			trie.WriteTo(&buf)
+			loaded := b.newTrie()
+
+			if _, err := loaded.ReadFrom(&buf); err != nil {
+				t.Fatalf(`Expected to load into %s, got %v.`, b.name, err)
+			}
+
+			if res := loaded.TopK([]byte{}, -1); !sameCompletions(res, expected) {
+				t.Fatalf(`Expected %s to have the same TopK after loading, got %v.`, b.name, res)
+			}
+		}
+
+		sub := trie.Sub([]byte("b"))
+
+		var buf bytes.Buffer
+		sub.WriteTo(&buf)
+		loaded := New().Sub([]byte("b"))
+
+		if _, err := loaded.ReadFrom(&buf); err != nil {
+			t.Fatalf(`Expected to load into a Sub, got %v.`, err)
+		}
+
+		if res := loaded.TopK([]byte{}, 5); !sameCompletions(res, sub.TopK([]byte{}, 5)) {
+			t.Fatalf(`Expected the Sub to have the same TopK after loading, got %v.`, res)
+		}
+	})
+}
+
+// Tries saved before there were weights should still load, with everything at
+// a weight of 0.
+func TestSerializeVersion1(t *testing.T) {
+	body := []byte(formatMagic)
+	body = append(body, 1, layoutEntries, 5)
+	body = append(body, "bytes"...)
+	body = append(body, 1, 3)
+	body = append(body, "key"...)
+	body = append(body, 5)
+	body = append(body, "value"...)
+	body = binary.BigEndian.AppendUint32(body, crc32.ChecksumIEEE(body))
+
+	trie := New(WithCodec(BytesCodec{}))
+
+	if _, err := trie.ReadFrom(bytes.NewReader(body)); err != nil {
+		t.Fatalf(`Expected to load version 1, got %v.`, err)
+	}
+
+	if res := trie.TopK([]byte{}, -1); len(res) != 1 || string(res[0].Key) != "key" || string(res[0].Value.([]byte)) != "value" || res[0].Weight != 0 {
+		t.Fatalf(`Expected "key" at 0, got %v.`, res)
+	}
+}
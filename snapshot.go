@@ -0,0 +1,352 @@
+package trie
+
+import (
+	"math"
+	"sort"
+)
+
+// A Snapshot is a read-only view of a Trie as it was when Snapshot was called.
+// Writes to the trie after that don't show up in it, so it's a good way to get
+// a consistent Range or iteration while the trie keeps changing underneath.
+//
+// Snapshots of the default backend are cheap to take, and old values only get
+// kept around for as long as there's a snapshot that can see them. The other
+// backends take a copy. Like the trie itself, a snapshot isn't safe to read on
+// one goroutine while another one writes to the trie unless the trie came from
+// NewConcurrent.
+type Snapshot interface {
+	Reader
+
+	// Release lets the trie forget anything it was only hanging on to for
+	// this snapshot. The snapshot can't be used afterwards.
+	Release()
+}
+
+type snapshot struct {
+	Reader
+	release func()
+}
+
+// Snapshots can be the other trie for a Sub, which needs to get at the weights.
+func (self *snapshot) weigh(key []byte) float64 {
+	return self.Reader.(weigher).weigh(key)
+}
+
+func (self *snapshot) Release() {
+	if self.release != nil {
+		self.release()
+		self.release = nil
+	}
+}
+
+// What a node used to look like, from rev up until the next revision in its
+// history (or the node's own rev, for the last one). The size and maxWeight
+// change whenever anything underneath the node does, so snapshots can still
+// use them to skip over whole subtrees.
+type revision struct {
+	rev       uint64
+	value     interface{}
+	hasValue  bool
+	weight    float64
+	size      int
+	maxWeight float64
+}
+
+// versions keeps track of the snapshots that are open on a trie, so that writes
+// know which old values need to be kept. Only the root has one, and only once
+// a snapshot has been taken.
+//
+// Revisions only move forward while there are snapshots open, since there's
+// nobody to tell them apart otherwise. They start at 1 so that 0 can mean the
+// live trie.
+type versions struct {
+	rev uint64
+
+	// The revisions that snapshots are open at, in order, along with how
+	// many are open at each.
+	open []uint64
+	refs map[uint64]int
+
+	// Every node that has some history, so we can clean them up without
+	// walking the whole trie.
+	nodes map[*trieImpl]struct{}
+}
+
+func newVersions() *versions {
+	return &versions{
+		rev:   1,
+		refs:  make(map[uint64]int),
+		nodes: make(map[*trieImpl]struct{}),
+	}
+}
+
+// active reports whether there are any snapshots to keep history for.
+func (self *versions) active() bool {
+	return self != nil && len(self.open) > 0
+}
+
+func (self *versions) pin() uint64 {
+	if self.refs[self.rev] == 0 {
+		self.open = append(self.open, self.rev)
+	}
+
+	self.refs[self.rev] += 1
+	return self.rev
+}
+
+func (self *versions) unpin(rev uint64) {
+	self.refs[rev] -= 1
+
+	if self.refs[rev] > 0 {
+		return
+	}
+
+	delete(self.refs, rev)
+	i := sort.Search(len(self.open), func(i int) bool {
+		return self.open[i] >= rev
+	})
+	self.open = append(self.open[:i], self.open[i+1:]...)
+
+	for node := range self.nodes {
+		self.collect(node)
+	}
+}
+
+// needed reports whether any open snapshot falls between from (inclusive) and
+// to (exclusive).
+func (self *versions) needed(from, to uint64) bool {
+	i := sort.Search(len(self.open), func(i int) bool {
+		return self.open[i] >= from
+	})
+
+	return i < len(self.open) && self.open[i] < to
+}
+
+// save is called before a write changes node at rev, and hangs on to how it
+// looks now if there's a snapshot that can still see it.
+func (self *versions) save(node *trieImpl, rev uint64) {
+	if self.needed(node.rev, rev) {
+		node.history = append(node.history, node.state(0))
+		self.nodes[node] = struct{}{}
+	}
+
+	node.rev = rev
+}
+
+// collect throws away whatever history no snapshot can see anymore. If that
+// leaves the node with nothing in it, it gets pruned like Delete would have
+// done in the first place.
+func (self *versions) collect(node *trieImpl) {
+	history := node.history[:0]
+
+	for i, r := range node.history {
+		to := node.rev
+
+		if i+1 < len(node.history) {
+			to = node.history[i+1].rev
+		}
+
+		if self.needed(r.rev, to) {
+			history = append(history, r)
+		}
+	}
+
+	// Don't hang on to the old values through the tail of the slice.
+	for i := len(history); i < len(node.history); i++ {
+		node.history[i] = revision{}
+	}
+
+	node.history = history
+
+	if len(history) > 0 {
+		return
+	}
+
+	node.history = nil
+	delete(self.nodes, node)
+
+	for node.parent != nil && node.empty() {
+		parent := node.parent
+
+		for i, child := range parent.children {
+			if child == node {
+				parent.removeChild(i)
+				break
+			}
+		}
+
+		node = parent
+	}
+}
+
+// state returns what the node looked like as of rev, or what it looks like now
+// if rev is 0.
+func (self *trieImpl) state(rev uint64) revision {
+	if rev == 0 || self.rev <= rev {
+		return revision{self.rev, self.value, self.hasValue, self.weight, self.size, self.maxWeight}
+	}
+
+	for i := len(self.history) - 1; i >= 0; i-- {
+		if self.history[i].rev <= rev {
+			return self.history[i]
+		}
+	}
+
+	// The node didn't have anything in it yet.
+	return revision{maxWeight: math.Inf(-1)}
+}
+
+// at returns the value the node had as of rev, or its current value if rev is
+// 0.
+func (self *trieImpl) at(rev uint64) (interface{}, bool) {
+	s := self.state(rev)
+	return s.value, s.hasValue
+}
+
+func (self *trieImpl) has(rev uint64) bool {
+	_, ok := self.at(rev)
+	return ok
+}
+
+// Snapshot returns a view of the trie as it is right now. Taking one is cheap,
+// but until it's released, every value that gets overwritten or deleted has to
+// be kept around for it.
+func (self *trieImpl) Snapshot() Snapshot {
+	if self.versions == nil {
+		self.versions = newVersions()
+	}
+
+	rev := self.versions.pin()
+	view := &trieSnapshot{root: self, rev: rev}
+
+	return &snapshot{
+		Reader: &ordered{store: view, opts: self.opts},
+		release: func() {
+			self.versions.unpin(rev)
+		},
+	}
+}
+
+// path returns the nodes on the way down to key, as far as they go.
+func (self *trieImpl) path(key []byte) []*trieImpl {
+	path := []*trieImpl{self}
+	node := self
+
+	for _, b := range key {
+		node = node.find([]byte{b})
+
+		if node == nil {
+			break
+		}
+
+		path = append(path, node)
+	}
+
+	return path
+}
+
+// versioned applies a write to the node for key while there are snapshots
+// open. Every node on the way down changes size and maybe maxWeight along with
+// it, so they all get saved first.
+func (self *trieImpl) versioned(key []byte, write func()) {
+	rev := self.versions.rev + 1
+
+	for _, node := range self.path(key) {
+		self.versions.save(node, rev)
+	}
+
+	write()
+
+	// Anything the write added is new as of rev too.
+	for _, node := range self.path(key) {
+		node.rev = rev
+	}
+
+	self.versions.rev = rev
+}
+
+// trieSnapshot reads a trieImpl as of a revision. Everything else comes from
+// wrapping it up in ordered.
+type trieSnapshot struct {
+	root *trieImpl
+	rev  uint64
+}
+
+func (self *trieSnapshot) Get(key []byte) (interface{}, bool) {
+	node := self.root.find(key)
+
+	if node == nil {
+		return nil, false
+	}
+
+	return node.at(self.rev)
+}
+
+func (self *trieSnapshot) weigh(key []byte) float64 {
+	node := self.root.find(key)
+
+	if node == nil {
+		return 0
+	}
+
+	return node.state(self.rev).weight
+}
+
+func (self *trieSnapshot) topK(prefix []byte, k int) []Completion {
+	return self.root.topKAt(self.rev, prefix, k)
+}
+
+func (self *trieSnapshot) Iterator() Iterator {
+	return newTrieIterator(self.root, self.rev, false)
+}
+
+func (self *trieSnapshot) ReverseIterator() Iterator {
+	return newTrieIterator(self.root, self.rev, true)
+}
+
+func (self *trieSnapshot) Len() int {
+	return self.root.state(self.rev).size
+}
+
+// Snapshots only ever get handed out as a Reader, so nothing can call these.
+func (self *trieSnapshot) Insert(key []byte, val interface{}) {
+	panic("trie: snapshots are read-only")
+}
+
+func (self *trieSnapshot) InsertWeighted(key []byte, val interface{}, weight float64) {
+	panic("trie: snapshots are read-only")
+}
+
+func (self *trieSnapshot) Delete(key []byte) bool {
+	panic("trie: snapshots are read-only")
+}
+
+// Backends can implement snapshotter if they have a better way to take a
+// snapshot than copying everything.
+type snapshotter interface {
+	snapshot() store
+}
+
+// Snapshot returns a copy of the trie as it is right now.
+func (self *ordered) Snapshot() Snapshot {
+	view := &ordered{opts: self.opts}
+
+	if s, ok := self.store.(snapshotter); ok {
+		view.store = s.snapshot()
+	} else {
+		view.store = self.copy()
+	}
+
+	return &snapshot{Reader: view}
+}
+
+// copy puts everything into a fresh store, weights and all.
+func (self *ordered) copy() store {
+	fresh := self.opts.backend()
+
+	for it := self.Iterator(); it.Valid(); it.Next() {
+		fresh.InsertWeighted(it.Key(), it.Value(), self.weigh(it.Key()))
+	}
+
+	return fresh
+}
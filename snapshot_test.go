@@ -0,0 +1,256 @@
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSnapshotIgnoresLaterWrites(t *testing.T) {
+	trie := setupTrie()
+	snap := trie.Snapshot()
+	defer snap.Release()
+
+	trie.Insert([]byte("20140901"), "Changed")
+	trie.Insert([]byte("20140912"), "20140912")
+	trie.Delete([]byte("20140905"))
+
+	if snap.Lookup([]byte("20140901")) != "20140901" {
+		t.Fatalf(`Expected "20140901" to be unchanged, got %v.`, snap.Lookup([]byte("20140901")))
+	}
+
+	if snap.Has([]byte("20140912")) {
+		t.Fatalf(`Expected "20140912" not to show up in the snapshot.`)
+	}
+
+	if !snap.Has([]byte("20140905")) {
+		t.Fatalf(`Expected "20140905" to still be in the snapshot.`)
+	}
+
+	if snap.Len() != 11 {
+		t.Fatalf(`Expected a length of 11, got %d.`, snap.Len())
+	}
+
+	if c := snap.CountPrefix([]byte("201409")); c != 11 {
+		t.Fatalf(`Expected 11 keys under "201409", got %d.`, c)
+	}
+
+	vals := snap.Range([]byte("20140901"), []byte("20140912"))
+
+	if len(vals) != 11 {
+		t.Fatalf(`Expected length of vals to be 11, got %d.`, len(vals))
+	}
+
+	if trie.Lookup([]byte("20140901")) != "Changed" || trie.Len() != 11 || trie.Has([]byte("20140905")) {
+		t.Fatalf(`Expected the trie itself to have all the changes.`)
+	}
+}
+
+func TestSnapshotIteratesWhileWriting(t *testing.T) {
+	trie := setupTrie()
+	snap := trie.Snapshot()
+	defer snap.Release()
+
+	count := 0
+
+	// Delete the key after every one we see, and add another in front of it.
+	for it := snap.Iterator(); it.Valid(); it.Next() {
+		trie.Delete(it.Key())
+		trie.Insert(append(append([]byte{}, it.Key()...), '0'), "new")
+		count += 1
+	}
+
+	if count != 11 {
+		t.Fatalf(`Expected to see 11 keys, saw %d.`, count)
+	}
+
+	if trie.Len() != 11 || trie.CountPrefix([]byte("20140901")) != 1 {
+		t.Fatalf(`Expected every key in the trie to have been replaced.`)
+	}
+}
+
+func TestSnapshotSurvivesDeletePrefix(t *testing.T) {
+	trie := setupTrie()
+	snap := trie.Snapshot()
+	defer snap.Release()
+
+	trie.DeletePrefix([]byte("2014090"))
+	trie.DeleteRange([]byte("20140910"), []byte("20140911"))
+
+	if trie.Len() != 0 {
+		t.Fatalf(`Expected the trie to be empty, got %d.`, trie.Len())
+	}
+
+	if c := snap.CountPrefix([]byte("2014090")); c != 9 {
+		t.Fatalf(`Expected 9 keys under "2014090", got %d.`, c)
+	}
+
+	if snap.Lookup([]byte("20140911")) != "20140911" {
+		t.Fatalf(`Expected "20140911" to still be in the snapshot.`)
+	}
+}
+
+func TestSnapshotReleaseCollects(t *testing.T) {
+	trie := setupTrie()
+	root := trie.(*trieImpl)
+	nodes := countNodes(root)
+
+	first := trie.Snapshot()
+	trie.Insert([]byte("20140901"), "Changed")
+	second := trie.Snapshot()
+	trie.DeletePrefix([]byte("2014091"))
+
+	if countNodes(root) != nodes {
+		t.Fatalf(`Expected deleted nodes to be kept for the snapshots.`)
+	}
+
+	first.Release()
+
+	if second.Lookup([]byte("20140901")) != "Changed" || second.Lookup([]byte("20140910")) != "20140910" {
+		t.Fatalf(`Expected the second snapshot to be unaffected by releasing the first.`)
+	}
+
+	if len(root.find([]byte("20140901")).history) != 0 {
+		t.Fatalf(`Expected the history of "20140901" to have been collected.`)
+	}
+
+	second.Release()
+
+	if len(root.versions.nodes) != 0 {
+		t.Fatalf(`Expected no history to be left, got %d nodes.`, len(root.versions.nodes))
+	}
+
+	// Only "2014090" and the nodes above it are left.
+	if c := countNodes(root); c != nodes-3 {
+		t.Fatalf(`Expected %d nodes, got %d.`, nodes-3, c)
+	}
+
+	// Releasing twice doesn't do anything.
+	second.Release()
+}
+
+func TestSnapshotReadFrom(t *testing.T) {
+	trie := setupTrie()
+	var buf bytes.Buffer
+
+	other := New()
+	other.Insert([]byte("test1"), "Hello")
+	other.WriteTo(&buf)
+
+	snap := trie.Snapshot()
+	defer snap.Release()
+
+	if _, err := trie.ReadFrom(&buf); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if trie.Len() != 1 || snap.Len() != 11 || snap.Has([]byte("test1")) {
+		t.Fatalf(`Expected the snapshot to keep the old contents.`)
+	}
+}
+
+func TestSnapshotOtherBackends(t *testing.T) {
+	for _, newTrie := range []func() Trie{NewRadix, NewART, NewConcurrent} {
+		trie := newTrie()
+		trie.Insert([]byte("test1"), "Hello")
+
+		snap := trie.Snapshot()
+		trie.Insert([]byte("test1"), "World")
+		trie.Insert([]byte("test2"), "Again")
+
+		if snap.Lookup([]byte("test1")) != "Hello" || snap.Len() != 1 {
+			t.Fatalf(`Expected the snapshot not to see later writes.`)
+		}
+
+		snap.Release()
+	}
+}
+
+// Make a bunch of random changes with snapshots coming and going, checking
+// each snapshot against a copy of what the trie looked like.
+func TestSnapshotRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trie := New()
+	live := make(map[string]interface{})
+
+	type open struct {
+		snap     Snapshot
+		expected map[string]interface{}
+	}
+
+	snaps := make([]open, 0)
+
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("%x", rnd.Intn(64))
+
+		switch rnd.Intn(6) {
+		case 0:
+			expected := make(map[string]interface{})
+
+			for k, v := range live {
+				expected[k] = v
+			}
+
+			snaps = append(snaps, open{trie.Snapshot(), expected})
+		case 1:
+			if len(snaps) > 0 {
+				j := rnd.Intn(len(snaps))
+				snaps[j].snap.Release()
+				snaps = append(snaps[:j], snaps[j+1:]...)
+			}
+		case 2:
+			trie.Delete([]byte(key))
+			delete(live, key)
+		case 3:
+			trie.DeletePrefix([]byte(key[:1]))
+
+			for k := range live {
+				if k[0] == key[0] {
+					delete(live, k)
+				}
+			}
+		default:
+			trie.Insert([]byte(key), i)
+			live[key] = i
+		}
+
+		for _, s := range snaps {
+			if s.snap.Len() != len(s.expected) {
+				t.Fatalf(`Expected a length of %d, got %d.`, len(s.expected), s.snap.Len())
+			}
+
+			count := 0
+
+			for it := s.snap.Iterator(); it.Valid(); it.Next() {
+				if s.expected[string(it.Key())] != it.Value() {
+					t.Fatalf(`Expected %q to be %v, got %v.`, it.Key(), s.expected[string(it.Key())], it.Value())
+				}
+
+				count += 1
+			}
+
+			if count != len(s.expected) {
+				t.Fatalf(`Expected to iterate over %d keys, got %d.`, len(s.expected), count)
+			}
+		}
+	}
+
+	for _, s := range snaps {
+		s.snap.Release()
+	}
+
+	if n := len(trie.(*trieImpl).versions.nodes); n != 0 {
+		t.Fatalf(`Expected no history to be left, got %d nodes.`, n)
+	}
+
+	fresh := New()
+
+	for k, v := range live {
+		fresh.Insert([]byte(k), v)
+	}
+
+	if countNodes(trie.(*trieImpl)) != countNodes(fresh.(*trieImpl)) {
+		t.Fatalf(`Expected every node that was only kept for a snapshot to be pruned.`)
+	}
+}
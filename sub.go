@@ -0,0 +1,209 @@
+package trie
+
+import (
+	"bytes"
+	"io"
+)
+
+// sub is a store made out of the keys in another trie that start with prefix,
+// with the prefix taken off. Everything goes straight through to the other
+// trie, so the two always agree.
+type sub struct {
+	parent Reader
+	prefix []byte
+}
+
+// full puts the prefix back on key.
+func (self *sub) full(key []byte) []byte {
+	return append(append(make([]byte, 0, len(self.prefix)+len(key)), self.prefix...), key...)
+}
+
+// trie returns the trie we're a part of for writing to. Subs of snapshots are
+// only ever handed out as a Reader, so nothing should end up in the panic.
+func (self *sub) trie() Trie {
+	t, ok := self.parent.(Trie)
+
+	if !ok {
+		panic("trie: snapshots are read-only")
+	}
+
+	return t
+}
+
+func (self *sub) Insert(key []byte, val interface{}) {
+	self.trie().Insert(self.full(key), val)
+}
+
+// Weights live in the other trie, so that its TopK can use them.
+func (self *sub) InsertWeighted(key []byte, val interface{}, weight float64) {
+	self.trie().InsertWeighted(self.full(key), val, weight)
+}
+
+func (self *sub) Get(key []byte) (interface{}, bool) {
+	return self.parent.Get(self.full(key))
+}
+
+func (self *sub) weigh(key []byte) float64 {
+	return self.parent.(weigher).weigh(self.full(key))
+}
+
+func (self *sub) Delete(key []byte) bool {
+	return self.trie().Delete(self.full(key))
+}
+
+func (self *sub) Iterator() Iterator {
+	it := &subIterator{it: self.parent.Iterator(), prefix: self.prefix}
+	it.Seek([]byte{})
+	return it
+}
+
+func (self *sub) ReverseIterator() Iterator {
+	it := &subIterator{it: self.parent.ReverseIterator(), prefix: self.prefix}
+	seekPrefixReverse(it.it, self.prefix)
+	return it
+}
+
+func (self *sub) Len() int {
+	return self.parent.CountPrefix(self.prefix)
+}
+
+func (self *sub) CountPrefix(prefix []byte) int {
+	return self.parent.CountPrefix(self.full(prefix))
+}
+
+func (self *sub) DeletePrefix(prefix []byte) int {
+	return self.trie().DeletePrefix(self.full(prefix))
+}
+
+func (self *sub) hold() func() {
+	if h, ok := self.parent.(holder); ok {
+		return h.hold()
+	}
+
+	return func() {}
+}
+
+// Transactions go through to a transaction on the other trie, so they're just
+// as atomic there.
+func (self *sub) commit(txn *Txn) {
+	outer := self.trie().Begin()
+
+	txn.each(func(key []byte, val interface{}, weight float64, deleted bool) {
+		if deleted {
+			outer.Delete(self.full(key))
+		} else {
+			outer.InsertWeighted(self.full(key), val, weight)
+		}
+	})
+
+	outer.Commit()
+}
+
+// topK asks the other trie and takes the prefix back off what it finds.
+func (self *sub) topK(prefix []byte, k int) []Completion {
+	res := self.parent.TopK(self.full(prefix), k)
+
+	for i := range res {
+		res[i].Key = res[i].Key[len(self.prefix):]
+	}
+
+	return res
+}
+
+// subIterator walks the keys of another iterator that start with prefix.
+type subIterator struct {
+	it     Iterator
+	prefix []byte
+}
+
+func (self *subIterator) Seek(key []byte) {
+	self.it.Seek(append(append([]byte{}, self.prefix...), key...))
+}
+
+func (self *subIterator) Next() {
+	self.it.Next()
+}
+
+func (self *subIterator) Valid() bool {
+	return self.it.Valid() && bytes.HasPrefix(self.it.Key(), self.prefix)
+}
+
+func (self *subIterator) Key() []byte {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.it.Key()[len(self.prefix):]
+}
+
+func (self *subIterator) Value() interface{} {
+	if !self.Valid() {
+		return nil
+	}
+
+	return self.it.Value()
+}
+
+// subTrie is what Sub hands out. Most of it is filled in by ordered on top of
+// sub, but a few things need to go through to the other trie themselves.
+type subTrie struct {
+	*ordered
+	sub *sub
+}
+
+func newSub(parent Trie, prefix []byte, opts *options) Trie {
+	s := &sub{parent: parent, prefix: append([]byte{}, prefix...)}
+	return &subTrie{ordered: &ordered{store: s, opts: opts}, sub: s}
+}
+
+// Subs of subs just use the whole prefix on the original trie.
+func (self *subTrie) Sub(prefix []byte) Trie {
+	return self.sub.trie().Sub(self.sub.full(prefix))
+}
+
+func (self *subTrie) Snapshot() Snapshot {
+	snap := self.sub.trie().Snapshot()
+	view := &sub{parent: snap, prefix: self.sub.prefix}
+
+	return &snapshot{
+		Reader:  &ordered{store: view, opts: self.opts},
+		release: snap.Release,
+	}
+}
+
+// ReadFrom replaces everything under the prefix, and leaves the rest of the
+// other trie alone.
+func (self *subTrie) ReadFrom(r io.Reader) (int64, error) {
+	loaded := make([]Completion, 0)
+
+	n, err := load(r, self.opts.codec, func(key []byte, val interface{}, weight float64) {
+		loaded = append(loaded, Completion{self.sub.full(key), val, weight})
+	})
+
+	if err != nil {
+		return n, err
+	}
+
+	txn := self.sub.trie().Begin()
+
+	for it := self.Iterator(); it.Valid(); it.Next() {
+		txn.Delete(self.sub.full(it.Key()))
+	}
+
+	for _, c := range loaded {
+		txn.InsertWeighted(c.Key, c.Value, c.Weight)
+	}
+
+	return n, txn.Commit()
+}
+
+// Sub returns a view of the keys that start with prefix, with the prefix taken
+// off. Writes to it go to this trie with the prefix put back on, and it sees
+// writes to this trie straight away.
+func (self *trieImpl) Sub(prefix []byte) Trie {
+	return newSub(self, prefix, self.opts)
+}
+
+func (self *ordered) Sub(prefix []byte) Trie {
+	return newSub(self, prefix, self.opts)
+}
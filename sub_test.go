@@ -0,0 +1,184 @@
+package trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newSubTestTrie returns a Sub with neighbours on both sides of its prefix,
+// which shouldn't ever show up in it.
+func newSubTestTrie(newTrie func() Trie) func() Trie {
+	return func() Trie {
+		parent := newTrie()
+		parent.Insert([]byte("table1#"), "before")
+		parent.Insert([]byte("table2"), "just before")
+		parent.Insert([]byte("table3#test1"), "after")
+		parent.Insert([]byte{'t', 'a', 'b', 'l', 'e', '2', '#' + 1}, "just after")
+		return parent.Sub([]byte("table2#"))
+	}
+}
+
+func TestSubSuite(t *testing.T) {
+	runSuite(t, newSubTestTrie(func() Trie { return New() }))
+}
+
+func TestConcurrentSubSuite(t *testing.T) {
+	runSuite(t, newSubTestTrie(NewConcurrent))
+}
+
+func TestSubMatchesTrie(t *testing.T) {
+	testMatchesTrie(t, newSubTestTrie(NewRadix)(), "abc")
+}
+
+func TestSub(t *testing.T) {
+	parent := New()
+	parent.Insert([]byte("table2#test1"), "Hello")
+	parent.Insert([]byte("table3#test1"), "Hallooo")
+
+	trie := parent.Sub([]byte("table2#"))
+	trie.Insert([]byte("test2"), "World")
+
+	if parent.Lookup([]byte("table2#test2")) != "World" {
+		t.Fatalf(`Expected "table2#test2" to be "World", got %v.`, parent.Lookup([]byte("table2#test2")))
+	}
+
+	// It's a view, not a copy.
+	parent.Insert([]byte("table2#test3"), "Yes")
+
+	if trie.Lookup([]byte("test3")) != "Yes" {
+		t.Fatalf(`Expected "test3" to be "Yes", got %v.`, trie.Lookup([]byte("test3")))
+	}
+
+	vals := trie.Prefix([]byte("test"))
+
+	if len(vals) != 3 || vals["test1"] != "Hello" {
+		t.Fatalf(`Expected the 3 keys without the prefix, got %v.`, vals)
+	}
+
+	if vals := trie.Range([]byte("test2"), []byte("test3")); len(vals) != 2 || vals["test2"] != "World" {
+		t.Fatalf(`Expected "test2" and "test3", got %v.`, vals)
+	}
+
+	if trie.Len() != 3 {
+		t.Fatalf(`Expected length to be 3, got %d.`, trie.Len())
+	}
+
+	if !trie.Delete([]byte("test1")) || parent.Has([]byte("table2#test1")) {
+		t.Fatalf(`Expected "table2#test1" to be deleted.`)
+	}
+
+	if trie.Lookup([]byte("table3#test1")) != nil {
+		t.Fatalf(`Expected keys outside the prefix not to show up.`)
+	}
+}
+
+func TestSubOfSub(t *testing.T) {
+	parent := New()
+	parent.Insert([]byte("a/b/c"), 1)
+
+	trie := parent.Sub([]byte("a/")).Sub([]byte("b/"))
+	trie.Insert([]byte("d"), 2)
+
+	if trie.Lookup([]byte("c")) != 1 || parent.Lookup([]byte("a/b/d")) != 2 {
+		t.Fatalf(`Expected the prefixes to stack up.`)
+	}
+}
+
+func TestSubTxn(t *testing.T) {
+	parent := NewConcurrent()
+	parent.Insert([]byte("t#a"), 1)
+
+	trie := parent.Sub([]byte("t#"))
+	txn := trie.Begin()
+	txn.Delete([]byte("a"))
+	txn.Insert([]byte("b"), 2)
+
+	if !parent.Has([]byte("t#a")) || parent.Has([]byte("t#b")) {
+		t.Fatalf(`Expected nothing to change before the commit.`)
+	}
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if parent.Has([]byte("t#a")) || parent.Lookup([]byte("t#b")) != 2 {
+		t.Fatalf(`Expected the transaction to go through to the parent.`)
+	}
+}
+
+func TestSubSnapshot(t *testing.T) {
+	for _, newTrie := range []func() Trie{func() Trie { return New() }, NewRadix, NewConcurrent} {
+		parent := newTrie()
+		parent.Insert([]byte("t#a"), 1)
+
+		trie := parent.Sub([]byte("t#"))
+		snap := trie.Snapshot()
+		trie.Insert([]byte("b"), 2)
+		parent.Delete([]byte("t#a"))
+
+		if snap.Lookup([]byte("a")) != 1 || snap.Has([]byte("b")) || snap.Len() != 1 {
+			t.Fatalf(`Expected the snapshot to only have "a", got %v.`, snap.Prefix([]byte{}))
+		}
+
+		snap.Release()
+	}
+}
+
+func TestSubTopK(t *testing.T) {
+	parent := New()
+	parent.InsertWeighted([]byte("t#car"), 1, 5)
+	parent.InsertWeighted([]byte("u#car"), 2, 50)
+
+	trie := parent.Sub([]byte("t#"))
+	trie.InsertWeighted([]byte("cart"), 3, 10)
+
+	res := trie.TopK([]byte("ca"), -1)
+
+	if len(res) != 2 || string(res[0].Key) != "cart" || string(res[1].Key) != "car" {
+		t.Fatalf(`Expected "cart" then "car", got %v.`, res)
+	}
+}
+
+func TestSubSnapshotTopK(t *testing.T) {
+	for _, newTrie := range []func() Trie{func() Trie { return New() }, NewRadix, NewConcurrent} {
+		trie := newTrie().Sub([]byte("u#"))
+		trie.InsertWeighted([]byte("a"), 1, 1)
+		trie.InsertWeighted([]byte("b"), 2, 9)
+
+		snap := trie.Snapshot()
+		trie.InsertWeighted([]byte("a"), 1, 20)
+
+		if res := snap.TopK([]byte{}, 1); len(res) != 1 || string(res[0].Key) != "b" || res[0].Weight != 9 {
+			t.Fatalf(`Expected "b" at 9 in the snapshot, got %v.`, res)
+		}
+
+		if res := trie.TopK([]byte{}, 1); len(res) != 1 || string(res[0].Key) != "a" || res[0].Weight != 20 {
+			t.Fatalf(`Expected "a" at 20, got %v.`, res)
+		}
+
+		snap.Release()
+	}
+}
+
+func TestSubWriteTo(t *testing.T) {
+	parent := New()
+	parent.Insert([]byte("t#a"), "1")
+	parent.Insert([]byte("u#a"), "2")
+
+	var buf bytes.Buffer
+
+	if _, err := parent.Sub([]byte("t#")).WriteTo(&buf); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	// Loading it under a different prefix replaces just that prefix.
+	parent.Insert([]byte("u#b"), "3")
+
+	if _, err := parent.Sub([]byte("u#")).ReadFrom(&buf); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if parent.Len() != 2 || parent.Lookup([]byte("u#a")) != "1" || parent.Lookup([]byte("t#a")) != "1" {
+		t.Fatalf(`Expected "t#a" and "u#a", got %v.`, parent.Prefix([]byte{}))
+	}
+}
@@ -0,0 +1,167 @@
+package trie
+
+import (
+	"bytes"
+	"container/heap"
+)
+
+// A Completion is one of the results from TopK.
+type Completion struct {
+	Key    []byte
+	Value  interface{}
+	Weight float64
+}
+
+// better reports whether a should come before b in TopK results: heaviest
+// first, and in key order when the weights are the same.
+func better(aKey []byte, aWeight float64, bKey []byte, bWeight float64) bool {
+	if aWeight != bWeight {
+		return aWeight > bWeight
+	}
+
+	return bytes.Compare(aKey, bKey) < 0
+}
+
+// topNode is what TopK needs from a backend's nodes, the same way navNode is
+// what navigation needs. expand returns the node's own key, if it has one, and
+// hands each child that has any keys in it to visit, along with the bytes that
+// lead to it and the biggest weight of any key underneath it.
+type topNode interface {
+	expand(visit func(child topNode, label []byte, maxWeight float64)) (interface{}, float64, bool)
+}
+
+// A candidate is either a whole subtree, which is worth at most its node's
+// maxWeight, or a single key that's worth exactly its weight.
+type candidate struct {
+	node   topNode
+	key    []byte
+	value  interface{}
+	weight float64
+	leaf   bool
+}
+
+// candidates is a heap with the best candidate on top.
+type candidates []candidate
+
+func (self candidates) Len() int {
+	return len(self)
+}
+
+func (self candidates) Less(i, j int) bool {
+	return better(self[i].key, self[i].weight, self[j].key, self[j].weight)
+}
+
+func (self candidates) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+func (self *candidates) Push(x interface{}) {
+	*self = append(*self, x.(candidate))
+}
+
+func (self *candidates) Pop() interface{} {
+	old := *self
+	c := old[len(old)-1]
+	*self = old[:len(old)-1]
+	return c
+}
+
+// bestFirst returns the k heaviest keys under node, which is at key, that
+// start with prefix. Rather than looking at every key, we always open up
+// whichever subtree could have the heaviest key in it next, and stop as soon as
+// we've got k keys that beat everything left. Subtrees that the prefix doesn't
+// go through never get opened at all.
+func bestFirst(node topNode, key, prefix []byte, k int) []Completion {
+	res := make([]Completion, 0)
+
+	if k == 0 {
+		return res
+	}
+
+	h := &candidates{{node: node, key: key}}
+
+	for h.Len() > 0 && len(res) != k {
+		c := heap.Pop(h).(candidate)
+
+		if c.leaf {
+			res = append(res, Completion{c.key, c.value, c.weight})
+			continue
+		}
+
+		val, weight, ok := c.node.expand(func(child topNode, label []byte, maxWeight float64) {
+			if n := len(c.key); n < len(prefix) {
+				rest := prefix[n:]
+
+				if !bytes.HasPrefix(rest, label) && !bytes.HasPrefix(label, rest) {
+					return
+				}
+			}
+
+			key := append(append(make([]byte, 0, len(c.key)+len(label)), c.key...), label...)
+			heap.Push(h, candidate{node: child, key: key, weight: maxWeight})
+		})
+
+		if ok && bytes.HasPrefix(c.key, prefix) {
+			heap.Push(h, candidate{key: c.key, value: val, weight: weight, leaf: true})
+		}
+	}
+
+	return res
+}
+
+// trieAt is a node as it was at a revision, so that snapshots get searched the
+// same way as the trie itself.
+type trieAt struct {
+	node *trieImpl
+	rev  uint64
+}
+
+func (self trieAt) expand(visit func(child topNode, label []byte, maxWeight float64)) (interface{}, float64, bool) {
+	for _, child := range self.node.children {
+		// Nodes that are only hanging around for snapshots, or that came
+		// along after this one, don't have anything in them.
+		if s := child.state(self.rev); s.size > 0 {
+			visit(trieAt{child, self.rev}, []byte{child.key}, s.maxWeight)
+		}
+	}
+
+	s := self.node.state(self.rev)
+	return s.value, s.weight, s.hasValue
+}
+
+// TopK returns the k heaviest keys that start with prefix, heaviest first, or
+// all of them if k is negative.
+func (self *trieImpl) TopK(prefix []byte, k int) []Completion {
+	return self.topKAt(0, prefix, k)
+}
+
+// topKAt is TopK as of rev. We can go straight to the prefix, since every byte
+// has a node of its own.
+func (self *trieImpl) topKAt(rev uint64, prefix []byte, k int) []Completion {
+	node := self.find(prefix)
+
+	if node == nil {
+		return make([]Completion, 0)
+	}
+
+	return bestFirst(trieAt{node, rev}, append([]byte{}, prefix...), prefix, k)
+}
+
+func (self *trieImpl) weigh(key []byte) float64 {
+	if node := self.find(key); node != nil {
+		return node.weight
+	}
+
+	return 0
+}
+
+// Backends keep weights right next to their values, so a key and its weight
+// always change together. weigher is for getting at them from outside of a
+// store, like a Sub does with the trie it's a part of.
+type weigher interface {
+	weigh(key []byte) float64
+}
+
+func (self *ordered) TopK(prefix []byte, k int) []Completion {
+	return self.store.topK(prefix, k)
+}
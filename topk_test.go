@@ -0,0 +1,452 @@
+package trie
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	trie := New()
+	trie.InsertWeighted([]byte("car"), 1, 5)
+	trie.InsertWeighted([]byte("cart"), 2, 9)
+	trie.InsertWeighted([]byte("carbon"), 3, 2)
+	trie.InsertWeighted([]byte("care"), 4, 9)
+	trie.InsertWeighted([]byte("dog"), 5, 100)
+	trie.Insert([]byte("cab"), 6)
+
+	res := trie.TopK([]byte("ca"), 3)
+	expected := []string{"care", "cart", "car"}
+
+	if len(res) != len(expected) {
+		t.Fatalf(`Expected %d completions, got %d.`, len(expected), len(res))
+	}
+
+	// Ties come out in key order.
+	for i, key := range expected {
+		if string(res[i].Key) != key {
+			t.Fatalf(`Expected completion %d to be %q, got %q.`, i, key, res[i].Key)
+		}
+	}
+
+	if res[0].Value != 4 || res[0].Weight != 9 {
+		t.Fatalf(`Expected "care" to have value 4 and weight 9, got %v and %v.`, res[0].Value, res[0].Weight)
+	}
+
+	if res := trie.TopK([]byte("ca"), -1); len(res) != 5 || string(res[4].Key) != "cab" || res[4].Weight != 0 {
+		t.Fatalf(`Expected all 5 keys, ending with "cab" at 0, got %v.`, res)
+	}
+
+	if res := trie.TopK([]byte{}, 1); len(res) != 1 || string(res[0].Key) != "dog" {
+		t.Fatalf(`Expected only "dog", got %v.`, res)
+	}
+
+	if res := trie.TopK([]byte("x"), 3); len(res) != 0 {
+		t.Fatalf(`Expected no completions, got %v.`, res)
+	}
+
+	if res := trie.TopK([]byte("ca"), 0); len(res) != 0 {
+		t.Fatalf(`Expected no completions, got %v.`, res)
+	}
+}
+
+func TestTopKNegativeWeights(t *testing.T) {
+	trie := New()
+	trie.InsertWeighted([]byte("a"), 1, -5)
+	trie.InsertWeighted([]byte("ab"), 2, -1)
+	trie.InsertWeighted([]byte("abc"), 3, -3)
+
+	res := trie.TopK([]byte("a"), 2)
+
+	if len(res) != 2 || string(res[0].Key) != "ab" || string(res[1].Key) != "abc" {
+		t.Fatalf(`Expected "ab" then "abc", got %v.`, res)
+	}
+}
+
+func TestTopKAfterChanges(t *testing.T) {
+	trie := New()
+	trie.InsertWeighted([]byte("abc"), 1, 10)
+	trie.InsertWeighted([]byte("abd"), 2, 5)
+	trie.InsertWeighted([]byte("abe"), 3, 1)
+
+	// Writing over the heaviest key has to bring the max down with it.
+	trie.InsertWeighted([]byte("abc"), 1, 0)
+
+	if res := trie.TopK([]byte("ab"), 1); len(res) != 1 || string(res[0].Key) != "abd" {
+		t.Fatalf(`Expected "abd", got %v.`, res)
+	}
+
+	trie.Delete([]byte("abd"))
+
+	if res := trie.TopK([]byte("ab"), 1); len(res) != 1 || string(res[0].Key) != "abe" {
+		t.Fatalf(`Expected "abe", got %v.`, res)
+	}
+
+	// A plain Insert puts the weight back to 0.
+	trie.Insert([]byte("abe"), 3)
+	trie.InsertWeighted([]byte("abf"), 4, 2)
+
+	if res := trie.TopK([]byte("ab"), 1); len(res) != 1 || string(res[0].Key) != "abf" {
+		t.Fatalf(`Expected "abf", got %v.`, res)
+	}
+
+	trie.DeletePrefix([]byte("abf"))
+
+	if res := trie.TopK([]byte("ab"), -1); len(res) != 2 || string(res[0].Key) != "abc" {
+		t.Fatalf(`Expected "abc" first, got %v.`, res)
+	}
+}
+
+// checkMaxWeights makes sure every node's maxWeight is the biggest weight of any
+// key underneath it.
+func checkMaxWeights(t *testing.T, node *trieImpl) float64 {
+	best := math.Inf(-1)
+
+	if node.hasValue {
+		best = node.weight
+	}
+
+	for _, child := range node.children {
+		if w := checkMaxWeights(t, child); child.size > 0 && w > best {
+			best = w
+		}
+	}
+
+	if node.size > 0 && node.maxWeight != best {
+		t.Fatalf(`Expected maxWeight to be %v, got %v.`, best, node.maxWeight)
+	}
+
+	return best
+}
+
+func TestTopKMaxWeights(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trie := New().(*trieImpl)
+
+	for i := 0; i < 2000; i++ {
+		key := []byte{byte('a' + rnd.Intn(4)), byte('a' + rnd.Intn(4)), byte('a' + rnd.Intn(4))}
+
+		switch rnd.Intn(4) {
+		case 0:
+			trie.Delete(key)
+		case 1:
+			trie.DeletePrefix(key[:1])
+		default:
+			trie.InsertWeighted(key, i, float64(rnd.Intn(50)))
+		}
+
+		checkMaxWeights(t, trie)
+	}
+}
+
+// countingNode counts how many nodes TopK opens up on its way to the answer.
+type countingNode struct {
+	topNode
+	expanded *int
+}
+
+func (self countingNode) expand(visit func(child topNode, label []byte, maxWeight float64)) (interface{}, float64, bool) {
+	*self.expanded += 1
+
+	return self.topNode.expand(func(child topNode, label []byte, maxWeight float64) {
+		visit(countingNode{child, self.expanded}, label, maxWeight)
+	})
+}
+
+func TestTopKPrunes(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		keys := generateKeys(4, "")
+
+		for _, key := range keys {
+			trie.InsertWeighted([]byte(key), key, float64(rnd.Intn(1000)))
+		}
+
+		var root topNode
+
+		switch trie := trie.(type) {
+		case *trieImpl:
+			root = trieAt{trie, 0}
+		case *ordered:
+			switch s := trie.store.(type) {
+			case *radixTrie:
+				root = s.root
+			case *artTrie:
+				root = s.root
+			case *concurrentTrie:
+				root = s.root
+			}
+		}
+
+		for _, prefix := range []string{"", "abc"} {
+			expanded := 0
+			res := bestFirst(countingNode{root, &expanded}, []byte{}, []byte(prefix), 10)
+			expected := trie.TopK([]byte(prefix), 10)
+
+			if len(res) != 10 || string(res[0].Key) != string(expected[0].Key) {
+				t.Fatalf(`Expected the same 10 keys as TopK for %q, got %v.`, prefix, res)
+			}
+
+			if expanded >= len(keys)/50 {
+				t.Fatalf(`Expected to open a small part of the %d keys for %q, opened %d nodes.`, len(keys), prefix, expanded)
+			}
+		}
+	})
+}
+
+// Snapshots of the default backend keep each node's maxWeight from back then,
+// so they get to skip most of the trie too.
+func TestSnapshotTopKPrunes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trie := New().(*trieImpl)
+	keys := generateKeys(4, "")
+	expected := make([]Completion, 0)
+
+	for _, key := range keys {
+		weight := float64(rnd.Intn(1000))
+		trie.InsertWeighted([]byte(key), key, weight)
+		expected = append(expected, Completion{[]byte(key), key, weight})
+	}
+
+	snap := trie.Snapshot()
+	defer snap.Release()
+
+	for _, key := range keys {
+		trie.InsertWeighted([]byte(key), key, float64(rnd.Intn(1000)))
+		trie.InsertWeighted([]byte(key+"z"), key, 1000)
+	}
+
+	sort.Slice(expected, func(i, j int) bool {
+		return better(expected[i].Key, expected[i].Weight, expected[j].Key, expected[j].Weight)
+	})
+
+	res := snap.TopK([]byte{}, 10)
+
+	for i := range res {
+		if string(res[i].Key) != string(expected[i].Key) || res[i].Weight != expected[i].Weight {
+			t.Fatalf(`Expected completion %d to be %v, got %v.`, i, expected[i], res[i])
+		}
+	}
+
+	view := snap.(*snapshot).Reader.(*ordered).store.(*trieSnapshot)
+	expanded := 0
+	bestFirst(countingNode{trieAt{trie, view.rev}, &expanded}, []byte{}, []byte{}, 10)
+
+	if expanded >= len(keys)/50 {
+		t.Fatalf(`Expected to open a small part of the %d keys, opened %d nodes.`, len(keys), expanded)
+	}
+}
+
+func TestSnapshotTopK(t *testing.T) {
+	for _, newTrie := range []func() Trie{func() Trie { return New() }, NewRadix, NewART, NewConcurrent} {
+		trie := newTrie()
+		trie.InsertWeighted([]byte("a"), 1, 3)
+		trie.InsertWeighted([]byte("b"), 2, 2)
+
+		snap := trie.Snapshot()
+		trie.InsertWeighted([]byte("a"), 1, 1)
+		trie.InsertWeighted([]byte("c"), 3, 10)
+
+		if res := snap.TopK([]byte{}, 1); len(res) != 1 || string(res[0].Key) != "a" || res[0].Weight != 3 {
+			t.Fatalf(`Expected "a" at 3 in the snapshot, got %v.`, res)
+		}
+
+		if res := trie.TopK([]byte{}, 2); len(res) != 2 || string(res[0].Key) != "c" || string(res[1].Key) != "b" {
+			t.Fatalf(`Expected "c" then "b", got %v.`, res)
+		}
+
+		snap.Release()
+	}
+}
+
+func TestPersistentTopK(t *testing.T) {
+	first := NewPersistent().InsertWeighted([]byte("a"), 1, 3).InsertWeighted([]byte("b"), 2, 2)
+	second := first.InsertWeighted([]byte("b"), 2, 5)
+
+	if res := first.TopK([]byte{}, 1); len(res) != 1 || string(res[0].Key) != "a" {
+		t.Fatalf(`Expected "a", got %v.`, res)
+	}
+
+	if res := second.TopK([]byte{}, 1); len(res) != 1 || string(res[0].Key) != "b" {
+		t.Fatalf(`Expected "b", got %v.`, res)
+	}
+
+	if res := second.Delete([]byte("b")).TopK([]byte{}, -1); len(res) != 1 || string(res[0].Key) != "a" {
+		t.Fatalf(`Expected only "a", got %v.`, res)
+	}
+}
+
+// Every backend should pick out the same keys as sorting the whole lot.
+func TestTopKMatchesSort(t *testing.T) {
+	allBackends(t, func(t *testing.T, trie Trie, rnd *rand.Rand) {
+		keys := generateKeys(3, "")
+
+		weights := make(map[string]float64)
+
+		for i := 0; i < 3000; i++ {
+			key := keys[rnd.Intn(len(keys))]
+
+			switch rnd.Intn(5) {
+			case 0:
+				trie.Delete([]byte(key))
+				delete(weights, key)
+			case 1:
+				trie.Insert([]byte(key), key)
+				weights[key] = 0
+			default:
+				weight := float64(rnd.Intn(100))
+				trie.InsertWeighted([]byte(key), key, weight)
+				weights[key] = weight
+			}
+		}
+
+		for _, prefix := range []string{"", "a", "ab", "zz", "abc"} {
+			expected := make([]Completion, 0)
+
+			for key, weight := range weights {
+				if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+					expected = append(expected, Completion{[]byte(key), key, weight})
+				}
+			}
+
+			sort.Slice(expected, func(i, j int) bool {
+				return better(expected[i].Key, expected[i].Weight, expected[j].Key, expected[j].Weight)
+			})
+
+			for _, k := range []int{1, 10, 100} {
+				res := trie.TopK([]byte(prefix), k)
+
+				if k > len(expected) {
+					k = len(expected)
+				}
+
+				if len(res) != k {
+					t.Fatalf(`Expected %d completions for %q, got %d.`, k, prefix, len(res))
+				}
+
+				for i := range res {
+					if string(res[i].Key) != string(expected[i].Key) || res[i].Weight != expected[i].Weight || res[i].Value != expected[i].Value {
+						t.Fatalf(`Expected completion %d for %q to be %v, got %v.`, i, prefix, expected[i], res[i])
+					}
+				}
+			}
+		}
+	})
+}
+
+// Every value is the same as its weight, so TopK should never see a value
+// without its weight, however the writes go in.
+func TestConcurrentTopKWeights(t *testing.T) {
+	trie := NewConcurrent()
+	writers, rounds := 4, 500
+
+	if testing.Short() {
+		rounds = 100
+	}
+
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	var wg, rg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+
+			for i := 0; i < rounds; i++ {
+				key := []byte(fmt.Sprintf("k%d", rnd.Intn(8)))
+				weight := float64(rnd.Intn(100) + 1)
+
+				switch rnd.Intn(4) {
+				case 0:
+					trie.Insert(key, 0.0)
+				case 1:
+					trie.Delete(key)
+				case 2:
+					txn := trie.Begin()
+					txn.Insert(key, 0.0)
+					txn.Insert([]byte("k8"), 0.0)
+					txn.Commit()
+				default:
+					trie.InsertWeighted(key, weight, weight)
+				}
+			}
+		}(w)
+	}
+
+	rg.Add(1)
+
+	go func() {
+		defer rg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			for _, c := range trie.TopK([]byte("k"), -1) {
+				if c.Value != c.Weight {
+					errs <- fmt.Errorf(`Expected %q to have weight %v, got %v.`, c.Key, c.Value, c.Weight)
+					return
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	rg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// Once the writes are done, the maxWeights should all be right again, so
+	// TopK agrees with sorting everything.
+	expected := make([]Completion, 0)
+
+	for it := trie.Iterator(); it.Valid(); it.Next() {
+		expected = append(expected, Completion{append([]byte{}, it.Key()...), it.Value(), it.Value().(float64)})
+	}
+
+	sort.Slice(expected, func(i, j int) bool {
+		return better(expected[i].Key, expected[i].Weight, expected[j].Key, expected[j].Weight)
+	})
+
+	if res := trie.TopK([]byte("k"), 3); !sameCompletions(res, expected[:minOf(3, len(expected))]) {
+		t.Fatalf(`Expected %v, got %v.`, expected, res)
+	}
+
+	var check func(node *concurrentNode) float64
+
+	check = func(node *concurrentNode) float64 {
+		best := math.Inf(-1)
+
+		if node.hasValue {
+			best = node.weight
+		}
+
+		for _, child := range node.children {
+			if w := check(child); w > best {
+				best = w
+			}
+		}
+
+		if node.maxWeight != best {
+			t.Fatalf(`Expected maxWeight to be %v, got %v.`, best, node.maxWeight)
+		}
+
+		return best
+	}
+
+	for _, child := range trie.(*ordered).store.(*concurrentTrie).root.children {
+		check(child)
+	}
+}
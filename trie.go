@@ -1,51 +1,156 @@
 package trie
 
+import (
+	"bytes"
+	"io"
+	"math"
+	"regexp/syntax"
+)
+
 // An implementation of a trie that supports a few common operations, namely
 // Lookup, Range, and Prefix. Pretty straight forward stuff. Figuring out the
 // type of the resultant object is an exercise for the reader.
 type Trie interface {
+	Reader
+
 	Insert(key []byte, val interface{})
+	Delete(key []byte) bool
+	DeletePrefix(prefix []byte) int
+	DeleteRange(start, end []byte) int
+
+	// InsertWeighted stores val at key with a weight for TopK to rank it
+	// by.
+	InsertWeighted(key []byte, val interface{}, weight float64)
+
+	// ReadFrom replaces the contents of the trie with what WriteTo saved.
+	ReadFrom(r io.Reader) (int64, error)
+
+	// Snapshot returns a read-only view of the trie as it is right now.
+	Snapshot() Snapshot
+
+	// Begin starts a transaction, which applies a group of writes all at
+	// once.
+	Begin() *Txn
+
+	// Sub returns a view of the keys that start with prefix, with the
+	// prefix taken off.
+	Sub(prefix []byte) Trie
+}
+
+// A Reader is the read-only half of a Trie.
+type Reader interface {
 	Lookup(key []byte) interface{}
+	Get(key []byte) (interface{}, bool)
+	Has(key []byte) bool
 	Range(start, end []byte) map[string]interface{}
 	RangeN(start, end []byte, n int) map[string]interface{}
 	OffsetRangeN(offset, start, end []byte, n int) map[string]interface{}
 	Prefix(prefix []byte) map[string]interface{}
 	PrefixN(prefix []byte, n int) map[string]interface{}
 	OffsetPrefixN(offset, prefix []byte, n int) map[string]interface{}
+	ReverseRangeN(start, end []byte, n int) map[string]interface{}
+	ReversePrefixN(prefix []byte, n int) map[string]interface{}
+	Iterator() Iterator
+	ReverseIterator() Iterator
 	Count() int
-	Delete(key []byte)
+	Len() int
+	CountPrefix(prefix []byte) int
+	CountRange(start, end []byte) int
+
+	// LongestPrefix returns the longest key in the trie that key starts with,
+	// which might be key itself.
+	LongestPrefix(key []byte) ([]byte, interface{}, bool)
+
+	// AllPrefixesOf returns every key in the trie that key starts with.
+	AllPrefixesOf(key []byte) map[string]interface{}
+
+	// Fuzzy returns the keys within maxDist edits of query, closest first.
+	Fuzzy(query []byte, maxDist, n int) []FuzzyMatch
+
+	// Match returns the keys that match a glob pattern.
+	Match(pattern []byte) (map[string]interface{}, error)
+
+	// Regexp returns up to n keys that match a regular expression.
+	Regexp(re *syntax.Regexp, n int) (map[string]interface{}, error)
+
+	// Min and Max return the smallest and biggest keys, and the others
+	// return the nearest key on one side of key, or key itself for Floor
+	// and Ceiling if it's there. ok is false if there's no such key.
+	Min() ([]byte, interface{}, bool)
+	Max() ([]byte, interface{}, bool)
+	Floor(key []byte) ([]byte, interface{}, bool)
+	Ceiling(key []byte) ([]byte, interface{}, bool)
+	Prev(key []byte) ([]byte, interface{}, bool)
+	Next(key []byte) ([]byte, interface{}, bool)
+
+	// Rank returns the number of keys smaller than key, and Select returns
+	// the key at that position. Every backend, and their snapshots and
+	// Subs, knows how big its subtrees are, so both take a single trip down
+	// the trie rather than counting keys.
+	Rank(key []byte) int
+	Select(i int) ([]byte, interface{}, bool)
+
+	// TopK returns the k heaviest keys that start with prefix.
+	TopK(prefix []byte, k int) []Completion
+
+	// These are the same as their N versions, except that the results
+	// come back in order.
+	OffsetRangeKV(offset, start, end []byte, n int) []KV
+	OffsetPrefixKV(offset, prefix []byte, n int) []KV
+	ReverseRangeKV(start, end []byte, n int) []KV
+	ReversePrefixKV(prefix []byte, n int) []KV
+
+	// The Bounded versions of the range reads can leave out either end,
+	// or not have one at all.
+	BoundedRangeN(offset []byte, bounds Bounds, n int) map[string]interface{}
+	BoundedRangeKV(offset []byte, bounds Bounds, n int) []KV
+	ReverseBoundedRangeN(bounds Bounds, n int) map[string]interface{}
+	ReverseBoundedRangeKV(bounds Bounds, n int) []KV
+
+	// RangePage and PrefixPage return a page of results at a time, with a
+	// cursor for the next one. The cursor has the last key in it, see Page.
+	RangePage(start, end []byte, cursor string, n int) (Page, error)
+	BoundedRangePage(bounds Bounds, cursor string, n int) (Page, error)
+	PrefixPage(prefix []byte, cursor string, n int) (Page, error)
+
+	// WriteTo saves the trie to w, encoding values with the trie's Codec.
+	WriteTo(w io.Writer) (int64, error)
 }
 
-type trieImpl struct {
-	key      byte
-	value    interface{}
-	parent   *trieImpl
-	children []*trieImpl
+// A KV is a key and its value, for results that need to stay in order.
+type KV struct {
+	Key   []byte
+	Value interface{}
 }
 
-func between(b, start, end byte) bool {
-	return b >= start && b <= end
-}
+type trieImpl struct {
+	key   byte
+	value interface{}
 
-func maxString(str []byte) []byte {
-	s := make([]byte, len(str))
+	// Whether or not a key ends at this node. We can't go by value alone as
+	// nil is a perfectly good thing to store.
+	hasValue bool
 
-	for i := range s {
-		s[i] = 0xFF
-	}
+	// The number of keys stored in this subtree, including this node.
+	size int
 
-	return s
-}
+	// The weight of this node's key, and the biggest weight of any key in
+	// the subtree, so TopK knows which way to go.
+	weight    float64
+	maxWeight float64
 
-func minString(str []byte) []byte {
-	s := make([]byte, len(str))
+	parent   *trieImpl
+	children []*trieImpl
 
-	// NOTE: This isn't strictly nescessary, it gets instantiated to 0s anyway.
-	for i := range s {
-		s[i] = 0x00
-	}
+	// The revision the current value was set at, and the values from before
+	// that which snapshots can still see.
+	rev     uint64
+	history []revision
 
-	return s
+	// How the trie was set up, and the snapshots that are open on it. Only
+	// the root has these.
+	opts     *options
+	versions *versions
 }
 
 func minOf(i1, i2 int) int {
@@ -114,15 +219,33 @@ func isOffsetLesser(offset, orig []byte) bool {
 	return true
 }
 
-func (self *trieImpl) getChildValues(res map[string]interface{}, prefix, offset []byte, n *int) {
+// emitter is how the traversals below hand back what they find. The key is
+// only good until the next call, so hang on to a copy of it.
+type emitter func(key []byte, val interface{})
+
+// intoMap collects results up into res.
+func intoMap(res map[string]interface{}) emitter {
+	return func(key []byte, val interface{}) {
+		res[string(key)] = val
+	}
+}
+
+// intoSlice collects results up into res, in the order they come in.
+func intoSlice(res *[]KV) emitter {
+	return func(key []byte, val interface{}) {
+		*res = append(*res, KV{append([]byte{}, key...), val})
+	}
+}
+
+func (self *trieImpl) getChildValues(emit emitter, prefix, offset []byte, n *int) {
 	// If n is exactly 0, we can finish visiting child nodes.
 	if *n == 0 {
 		return
 	}
 
-	if self.value != nil {
+	if self.hasValue {
 		if isOffsetLesser(offset, prefix) {
-			res[string(prefix)] = self.value
+			emit(prefix, self.value)
 
 			// One less to go.
 			if *n > 0 {
@@ -133,25 +256,139 @@ func (self *trieImpl) getChildValues(res map[string]interface{}, prefix, offset
 
 	for _, child := range self.children {
 		k := append(prefix, child.key)
-		child.getChildValues(res, k, offset, n)
+		child.getChildValues(emit, k, offset, n)
 	}
 }
 
+// Count returns the number of keys in the trie. It's the same as Len.
 func (self *trieImpl) Count() int {
-	totalChildren := 0
+	return self.Len()
+}
 
-	for _, trie := range self.children {
-		totalChildren += trie.Count()
+// Len returns the number of keys in the trie.
+func (self *trieImpl) Len() int {
+	return self.size
+}
+
+// find returns the node for key, or nil if there isn't one.
+func (self *trieImpl) find(key []byte) *trieImpl {
+	node := self
+
+	for _, b := range key {
+		var next *trieImpl
+
+		for _, child := range node.children {
+			if child.key == b {
+				next = child
+				break
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		node = next
+	}
+
+	return node
+}
+
+// countBelow returns the number of keys that are less than key as of rev, or
+// less than or equal to it if inclusive is set. We get there by adding up the
+// sizes of everything to the left of the path down to key.
+func (self *trieImpl) countBelow(rev uint64, key []byte, inclusive bool) int {
+	count := 0
+	node := self
+
+	for _, b := range key {
+		// This node is a prefix of the key, so it comes before it.
+		if node.has(rev) {
+			count += 1
+		}
+
+		var next *trieImpl
+
+		for _, child := range node.children {
+			if child.key >= b {
+				if child.key == b {
+					next = child
+				}
+
+				break
+			}
+
+			count += child.state(rev).size
+		}
+
+		if next == nil {
+			return count
+		}
+
+		node = next
+	}
+
+	// Everything below this node is bigger than key.
+	if inclusive && node.has(rev) {
+		count += 1
 	}
 
-	return len(self.children) + totalChildren
+	return count
+}
+
+// CountPrefix returns the number of keys that start with prefix.
+func (self *trieImpl) CountPrefix(prefix []byte) int {
+	node := self.find(prefix)
+
+	if node == nil {
+		return 0
+	}
+
+	return node.size
+}
+
+// CountRange returns the number of keys between start and end, inclusive.
+func (self *trieImpl) CountRange(start, end []byte) int {
+	if bytes.Compare(start, end) > 0 {
+		return 0
+	}
+
+	return self.countBelow(0, end, true) - self.countBelow(0, start, false)
 }
 
 func (self *trieImpl) Insert(key []byte, val interface{}) {
+	self.InsertWeighted(key, val, 0)
+}
+
+// InsertWeighted stores val at key with a weight for TopK to rank it by. Keys
+// that go in with plain Insert have a weight of 0.
+func (self *trieImpl) InsertWeighted(key []byte, val interface{}, weight float64) {
+	if self.versions.active() {
+		self.versioned(key, func() {
+			self.insert(key, val, weight)
+		})
+
+		return
+	}
+
+	self.insert(key, val, weight)
+}
+
+// insert stores val at key, returning true if the key wasn't there before.
+func (self *trieImpl) insert(key []byte, val interface{}, weight float64) bool {
 	// If we got to here that means that this element matches the key fully.
 	if len(key) == 0 {
+		added := !self.hasValue
 		self.value = val
-		return
+		self.hasValue = true
+		self.weight = weight
+
+		if added {
+			self.size += 1
+		}
+
+		self.reweigh()
+		return added
 	}
 
 	front := key[0]
@@ -159,8 +396,14 @@ func (self *trieImpl) Insert(key []byte, val interface{}) {
 	// No match yet, see if there is a child that matches.
 	for _, trie := range self.children {
 		if trie.key == front {
-			trie.Insert(key[1:len(key)], val)
-			return
+			added := trie.insert(key[1:len(key)], val, weight)
+
+			if added {
+				self.size += 1
+			}
+
+			self.reweigh()
+			return added
 		}
 	}
 
@@ -188,121 +431,378 @@ func (self *trieImpl) Insert(key []byte, val interface{}) {
 		self.children = append(self.children[:pos], append([]*trieImpl{trie}, self.children[pos:]...)...)
 	}
 
-	// Now let's drill in!
-	trie.Insert(key[1:len(key)], val)
+	// Now let's drill in! This is a brand new key, so it's always an addition.
+	trie.insert(key[1:len(key)], val, weight)
+	self.size += 1
+	self.reweigh()
+	return true
+}
+
+// reweigh works out the node's maxWeight again after something underneath it
+// has changed.
+func (self *trieImpl) reweigh() {
+	best := math.Inf(-1)
+
+	if self.hasValue {
+		best = self.weight
+	}
+
+	for _, child := range self.children {
+		if child.size > 0 && child.maxWeight > best {
+			best = child.maxWeight
+		}
+	}
+
+	self.maxWeight = best
 }
 
+// Lookup returns the value stored at key, or nil if there isn't one. Use Get
+// if you need to tell a stored nil apart from a missing key.
 func (self *trieImpl) Lookup(key []byte) interface{} {
+	val, _ := self.Get(key)
+	return val
+}
+
+// Get returns the value stored at key along with whether or not the key was
+// present at all.
+func (self *trieImpl) Get(key []byte) (interface{}, bool) {
 	if len(key) == 0 {
-		return self.value
+		return self.value, self.hasValue
 	}
 
 	front := key[0]
 
 	for _, trie := range self.children {
 		if trie.key == front {
-			return trie.Lookup(key[1:len(key)])
+			return trie.Get(key[1:len(key)])
 		}
 	}
 
 	// Didn't match anything.
-	return nil
+	return nil, false
+}
+
+func (self *trieImpl) Has(key []byte) bool {
+	_, ok := self.Get(key)
+	return ok
 }
 
-func (self *trieImpl) Delete(key []byte) {
+// prefixesOf walks down towards key the same way Get does, calling fn with the
+// length and value of every key it passes along the way.
+func (self *trieImpl) prefixesOf(key []byte, fn func(n int, val interface{})) {
+	node := self
+
+	for i := 0; ; i++ {
+		if node.hasValue {
+			fn(i, node.value)
+		}
+
+		if i == len(key) {
+			return
+		}
+
+		var next *trieImpl
+
+		for _, child := range node.children {
+			if child.key == key[i] {
+				next = child
+				break
+			}
+		}
+
+		if next == nil {
+			return
+		}
+
+		node = next
+	}
+}
+
+func (self *trieImpl) LongestPrefix(key []byte) ([]byte, interface{}, bool) {
+	n := -1
+	var val interface{}
+
+	self.prefixesOf(key, func(i int, v interface{}) {
+		n, val = i, v
+	})
+
+	if n < 0 {
+		return nil, nil, false
+	}
+
+	return append([]byte{}, key[:n]...), val, true
+}
+
+func (self *trieImpl) AllPrefixesOf(key []byte) map[string]interface{} {
+	res := make(map[string]interface{})
+
+	self.prefixesOf(key, func(n int, val interface{}) {
+		res[string(key[:n])] = val
+	})
+
+	return res
+}
+
+// Delete removes key from the trie, returning true if it was there. Any nodes
+// that are left without a value or children get cleaned up on the way out.
+func (self *trieImpl) Delete(key []byte) bool {
+	if self.versions.active() {
+		if !self.Has(key) {
+			return false
+		}
+
+		self.versioned(key, func() {
+			self.delete(key)
+		})
+
+		return true
+	}
+
+	return self.delete(key)
+}
+
+// empty reports whether or not this node is dead weight. Nodes that snapshots
+// can still see aren't, even if they don't have anything in them anymore.
+func (self *trieImpl) empty() bool {
+	return !self.hasValue && len(self.children) == 0 && len(self.history) == 0
+}
+
+func (self *trieImpl) removeChild(i int) {
+	self.children[i].parent = nil
+	self.children = append(self.children[:i], self.children[i+1:]...)
+}
+
+func (self *trieImpl) clear() {
+	for _, child := range self.children {
+		child.parent = nil
+	}
+
+	self.value = nil
+	self.hasValue = false
+	self.children = make([]*trieImpl, 0)
+	self.size = 0
+	self.weight = 0
+	self.maxWeight = math.Inf(-1)
+}
+
+// delete removes key, returning true if it was there to begin with.
+func (self *trieImpl) delete(key []byte) bool {
 	if len(key) == 0 {
+		if !self.hasValue {
+			return false
+		}
+
 		self.value = nil
-		return
+		self.hasValue = false
+		self.weight = 0
+		self.size -= 1
+		self.reweigh()
+		return true
 	}
 
 	front := key[0]
 
-	for _, trie := range self.children {
+	for i, trie := range self.children {
 		if trie.key == front {
-			trie.Delete(key[1:len(key)])
-			break
+			if !trie.delete(key[1:len(key)]) {
+				return false
+			}
+
+			if trie.empty() {
+				self.removeChild(i)
+			}
+
+			self.size -= 1
+			self.reweigh()
+			return true
 		}
 	}
+
+	return false
 }
 
-func (self *trieImpl) doRange(offset, start, end, prefix []byte, res map[string]interface{}, n *int) {
-	if self.key == 0 {
-		for _, child := range self.children {
-			child.doRange(offset, start, end, prefix, res, n)
-		}
+// DeletePrefix removes every key that starts with prefix, returning how many
+// keys were removed.
+func (self *trieImpl) DeletePrefix(prefix []byte) int {
+	// We can't throw away whole subtrees that snapshots are still using.
+	if self.versions.active() {
+		it := self.Iterator()
+		it.Seek(prefix)
+
+		return deleteKeys(it, self.Delete, func(key []byte) bool {
+			return bytes.HasPrefix(key, prefix)
+		})
+	}
 
-		return
+	if len(prefix) == 0 {
+		removed := self.size
+		self.clear()
+		return removed
 	}
 
-	// If both are empty then we completely matched.
-	if len(start) < 1 && len(end) < 1 {
-		self.getChildValues(res, append(prefix, self.key), offset, n)
-		return
+	front := prefix[0]
+
+	for i, trie := range self.children {
+		if trie.key == front {
+			var removed int
+
+			// The whole subtree goes, no need to look at any of it.
+			if len(prefix) == 1 {
+				removed = trie.size
+				self.removeChild(i)
+			} else {
+				removed = trie.DeletePrefix(prefix[1:])
+
+				if trie.empty() {
+					self.removeChild(i)
+				}
+			}
+
+			self.size -= removed
+			self.reweigh()
+			return removed
+		}
 	}
 
-	var startb, endb byte
+	return 0
+}
 
-	if len(start) > 0 {
-		startb = start[0]
-		start = start[1:]
+// DeleteRange removes every key between start and end, inclusive, returning
+// how many keys were removed.
+func (self *trieImpl) DeleteRange(start, end []byte) int {
+	if bytes.Compare(start, end) > 0 {
+		return 0
 	}
 
-	if len(end) > 0 {
-		endb = end[0]
-		end = end[1:]
+	if self.versions.active() {
+		it := self.Iterator()
+		it.Seek(start)
+
+		return deleteKeys(it, self.Delete, func(key []byte) bool {
+			return bytes.Compare(key, end) <= 0
+		})
+	}
+
+	return self.deleteRange([]byte{}, start, end)
+}
+
+func (self *trieImpl) deleteRange(prefix, start, end []byte) int {
+	removed := 0
+
+	if self.hasValue && bytes.Compare(prefix, start) >= 0 && bytes.Compare(prefix, end) <= 0 {
+		self.value = nil
+		self.hasValue = false
+		self.weight = 0
+		removed += 1
 	}
 
-	if (startb == self.key) && (endb == self.key) {
-		for _, child := range self.children {
-			child.doRange(offset, start, end, append(prefix, self.key), res, n)
+	for i := 0; i < len(self.children); i++ {
+		child := self.children[i]
+		k := append(prefix, child.key)
+
+		// Every key underneath the child starts with k, so if k is already
+		// past the end we're done.
+		if bytes.Compare(k, end) > 0 {
+			break
 		}
 
-		return
+		// Likewise, if k is before the start and the start doesn't go
+		// through this child then none of it is in range.
+		if bytes.Compare(k, start) < 0 && !bytes.HasPrefix(start, k) {
+			continue
+		}
+
+		// If the end doesn't go through this child either then the whole
+		// subtree is in range and can go in one shot.
+		if !bytes.HasPrefix(end, k) && bytes.Compare(k, start) >= 0 {
+			removed += child.size
+			self.removeChild(i)
+			i -= 1
+			continue
+		}
+
+		removed += child.deleteRange(k, start, end)
+
+		if child.empty() {
+			self.removeChild(i)
+			i -= 1
+		}
 	}
 
-	// Is there more work left to do?
+	self.size -= removed
+	self.reweigh()
+	return removed
+}
+
+// doRange visits the keys in bounds under this node, which is at prefix.
+// start and end are whatever's left of the bounds' keys after prefix, and
+// they're nil once prefix has gone past them, at which point they don't get
+// in the way anymore. We only ever get called on the root with the whole
+// bounds, so nothing here needs to know which node is the root, and any byte
+// at all can be part of a key.
+func (self *trieImpl) doRange(offset []byte, bounds Bounds, start, end, prefix []byte, emit emitter, n *int) {
 	if *n == 0 {
 		return
 	}
 
-	prefix = append(prefix, self.key)
+	// Nothing's holding us back on either side, so everything goes.
+	if start == nil && end == nil {
+		self.getChildValues(emit, prefix, offset, n)
+		return
+	}
 
-	if startb == self.key {
-		if self.value != nil && isOffsetLesser(offset, prefix) {
-			res[string(prefix)] = self.value
+	// Anything at prefix comes before everything longer, so it's too small
+	// if there's more of start to go. Otherwise it can only be left out
+	// for being exactly an exclusive bound.
+	atStart := start != nil && len(start) == 0
+	atEnd := end != nil && len(end) == 0
+	inside := len(start) == 0 &&
+		!(atStart && bounds.Start.Kind == Exclusive) &&
+		!(atEnd && bounds.End.Kind == Exclusive)
 
-			if *n > 0 {
-				*n -= 1
-			}
-		}
+	if self.hasValue && inside && isOffsetLesser(offset, prefix) {
+		emit(prefix, self.value)
 
-		// Nothing left to do.
-		if *n == 0 {
-			return
+		if *n > 0 {
+			*n -= 1
 		}
+	}
+
+	// Everything further down is bigger than end.
+	if atEnd {
+		return
+	}
+
+	for _, child := range self.children {
+		childStart, childEnd := []byte(nil), []byte(nil)
 
-		for _, child := range self.children {
-			child.doRange(offset, start, maxString(start), prefix, res, n)
+		if len(start) > 0 {
+			if child.key < start[0] {
+				continue
+			}
+
+			if child.key == start[0] {
+				childStart = start[1:]
+			}
 		}
-	} else if endb == self.key {
-		if self.value != nil && isOffsetLesser(offset, prefix) {
-			res[string(prefix)] = self.value
 
-			if *n > 0 {
-				*n -= 1
+		if end != nil {
+			// The children are in order, so the rest are too big as
+			// well.
+			if child.key > end[0] {
+				break
+			}
+
+			if child.key == end[0] {
+				childEnd = end[1:]
 			}
 		}
 
-		// Nothing left to do.
+		child.doRange(offset, bounds, childStart, childEnd, append(prefix, child.key), emit, n)
+
 		if *n == 0 {
 			return
 		}
-
-		for _, child := range self.children {
-			child.doRange(offset, minString(end), end, prefix, res, n)
-		}
-	} else if between(self.key, startb, endb) {
-		self.getChildValues(res, prefix, offset, n)
 	}
 }
 
@@ -314,17 +814,55 @@ func (self *trieImpl) RangeN(start, end []byte, n int) map[string]interface{} {
 	return self.OffsetRangeN([]byte{}, start, end, n)
 }
 
+// rangeFromRoot starts doRange off at the root. To doRange a nil key means
+// there isn't a bound, but a Bound with a nil key is just the empty key.
+func (self *trieImpl) rangeFromRoot(offset []byte, bounds Bounds, emit emitter, n int) {
+	start, end := bounds.Start.Key, bounds.End.Key
+
+	if bounds.Start.Kind == Unbounded {
+		start = nil
+	} else if start == nil {
+		start = []byte{}
+	}
+
+	if bounds.End.Kind == Unbounded {
+		end = nil
+	} else if end == nil {
+		end = []byte{}
+	}
+
+	self.doRange(offset, bounds, start, end, []byte(""), emit, &n)
+}
+
 func (self *trieImpl) OffsetRangeN(offset, start, end []byte, n int) map[string]interface{} {
+	return self.BoundedRangeN(offset, Between(start, end), n)
+}
+
+// OffsetRangeKV is OffsetRangeN, but with the results in order.
+func (self *trieImpl) OffsetRangeKV(offset, start, end []byte, n int) []KV {
+	return self.BoundedRangeKV(offset, Between(start, end), n)
+}
+
+// BoundedRangeN is OffsetRangeN with more say over where the range starts and
+// ends.
+func (self *trieImpl) BoundedRangeN(offset []byte, bounds Bounds, n int) map[string]interface{} {
 	results := make(map[string]interface{}, 0)
-	self.doRange(offset, start, end, []byte(""), results, &n)
+	self.rangeFromRoot(offset, bounds, intoMap(results), n)
 	return results
 }
 
-func (self *trieImpl) doPrefix(offset, prefix, orig []byte, res map[string]interface{}, n *int) {
+// BoundedRangeKV is BoundedRangeN, but with the results in order.
+func (self *trieImpl) BoundedRangeKV(offset []byte, bounds Bounds, n int) []KV {
+	res := make([]KV, 0)
+	self.rangeFromRoot(offset, bounds, intoSlice(&res), n)
+	return res
+}
+
+func (self *trieImpl) doPrefix(offset, prefix, orig []byte, emit emitter, n *int) {
 	// If there is an offset, we'll navigate down to it before we do our own
 	// thing.
 	if len(prefix) == 0 {
-		self.getChildValues(res, orig, offset, n)
+		self.getChildValues(emit, orig, offset, n)
 		return
 	}
 
@@ -337,7 +875,7 @@ func (self *trieImpl) doPrefix(offset, prefix, orig []byte, res map[string]inter
 
 	for _, trie := range self.children {
 		if trie.key == front {
-			trie.doPrefix(offset, prefix[1:len(prefix)], append(orig, front), res, n)
+			trie.doPrefix(offset, prefix[1:len(prefix)], append(orig, front), emit, n)
 			return
 		}
 	}
@@ -353,12 +891,149 @@ func (self *trieImpl) PrefixN(prefix []byte, n int) map[string]interface{} {
 
 func (self *trieImpl) OffsetPrefixN(offset, prefix []byte, n int) map[string]interface{} {
 	res := make(map[string]interface{})
-	self.doPrefix(offset, prefix, []byte{}, res, &n)
+	self.doPrefix(offset, prefix, []byte{}, intoMap(res), &n)
+	return res
+}
+
+// OffsetPrefixKV is OffsetPrefixN, but with the results in order.
+func (self *trieImpl) OffsetPrefixKV(offset, prefix []byte, n int) []KV {
+	res := make([]KV, 0)
+	self.doPrefix(offset, prefix, []byte{}, intoSlice(&res), &n)
+	return res
+}
+
+func (self *trieImpl) getChildValuesReverse(emit emitter, prefix []byte, n *int) {
+	// Children come after their parents, so they go first when we're walking
+	// backwards.
+	for i := len(self.children) - 1; i >= 0; i-- {
+		if *n == 0 {
+			return
+		}
+
+		child := self.children[i]
+		child.getChildValuesReverse(emit, append(prefix, child.key), n)
+	}
+
+	if *n != 0 && self.hasValue {
+		emit(prefix, self.value)
+
+		if *n > 0 {
+			*n -= 1
+		}
+	}
+}
+
+// ReverseRangeN returns the last n keys between start and end, inclusive.
+func (self *trieImpl) ReverseRangeN(start, end []byte, n int) map[string]interface{} {
+	return self.ReverseBoundedRangeN(Between(start, end), n)
+}
+
+// ReverseRangeKV is ReverseRangeN, but with the results in order, last first.
+func (self *trieImpl) ReverseRangeKV(start, end []byte, n int) []KV {
+	return self.ReverseBoundedRangeKV(Between(start, end), n)
+}
+
+// ReverseBoundedRangeN returns the last n keys in bounds.
+func (self *trieImpl) ReverseBoundedRangeN(bounds Bounds, n int) map[string]interface{} {
+	res := make(map[string]interface{})
+	boundedReverseRange(self.ReverseIterator(), bounds, intoMap(res), n)
+	return res
+}
+
+// ReverseBoundedRangeKV is ReverseBoundedRangeN, but with the results in
+// order, last first.
+func (self *trieImpl) ReverseBoundedRangeKV(bounds Bounds, n int) []KV {
+	res := make([]KV, 0)
+	boundedReverseRange(self.ReverseIterator(), bounds, intoSlice(&res), n)
+	return res
+}
+
+func (self *trieImpl) doReversePrefix(prefix []byte, emit emitter, n int) {
+	node := self.find(prefix)
+
+	// Nothing has this prefix.
+	if node == nil {
+		return
+	}
+
+	node.getChildValuesReverse(emit, append([]byte{}, prefix...), &n)
+}
+
+// ReversePrefixN returns the last n keys that start with prefix.
+func (self *trieImpl) ReversePrefixN(prefix []byte, n int) map[string]interface{} {
+	res := make(map[string]interface{})
+	self.doReversePrefix(prefix, intoMap(res), n)
+	return res
+}
+
+// ReversePrefixKV is ReversePrefixN, but with the results in order, last first.
+func (self *trieImpl) ReversePrefixKV(prefix []byte, n int) []KV {
+	res := make([]KV, 0)
+	self.doReversePrefix(prefix, intoSlice(&res), n)
 	return res
 }
 
-func New() Trie {
+func (self *trieImpl) Iterator() Iterator {
+	return newTrieIterator(self, 0, false)
+}
+
+func (self *trieImpl) ReverseIterator() Iterator {
+	return newTrieIterator(self, 0, true)
+}
+
+// An Option changes how New builds a Trie.
+type Option func(*options)
+
+type options struct {
+	// Builds the store for anything other than the default backend.
+	backend func() store
+
+	codec Codec
+}
+
+// WithRadix makes New return a path-compressed radix trie, which is a lot
+// easier on memory when keys are long and don't share much.
+func WithRadix() Option {
+	return func(o *options) {
+		o.backend = func() store {
+			return &radixTrie{root: new(radixNode)}
+		}
+	}
+}
+
+// WithART makes New return an adaptive radix tree, which keeps child lookups
+// fast even when a node has hundreds of children, as happens with binary keys.
+func WithART() Option {
+	return func(o *options) {
+		o.backend = func() store {
+			return &artTrie{root: new(artNode)}
+		}
+	}
+}
+
+// WithCodec sets the codec that WriteTo and ReadFrom use for values. The
+// default is GobCodec.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// New returns an empty Trie. By default there's a node for every byte of every
+// key, but the options can pick a different representation.
+func New(opts ...Option) Trie {
+	o := &options{codec: GobCodec{}}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.backend != nil {
+		return &ordered{store: o.backend(), opts: o}
+	}
+
 	trie := new(trieImpl)
 	trie.children = make([]*trieImpl, 0)
+	trie.opts = o
 	return trie
 }
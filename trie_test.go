@@ -1,11 +1,84 @@
 package trie
 
 import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"strings"
 	"testing"
 )
 
+// newTestTrie builds the trie that the tests in this file run against. Other
+// backends run through the same tests by way of runSuite.
+var newTestTrie = func() Trie {
+	return New()
+}
+
+// suite is the set of tests in this file that only go through the Trie
+// interface, and so should pass for any backend.
+var suite = []struct {
+	name string
+	test func(t *testing.T)
+}{
+	{"Lookup", TestTrieLookup},
+	{"RangeInclusive", TestTrieRangeInclusive},
+	{"RangeWithCommonSuffix", TestTrieRangeWithCommonSuffix},
+	{"RangeExclusive", TestTrieRangeExclusive},
+	{"RangePrefixed", TestTrieRangePrefixed},
+	{"RangeOutside", TestTrieRangeOutside},
+	{"RangePartiallyOutside", TestTrieRangePartiallyOutside},
+	{"OffsetRangeNPartiallyOutside", TestTrieOffsetRangeNPartiallyOutside},
+	{"RangeUnbalancedEnding", TestTrieRangeUnbalancedEnding},
+	{"OffsetRangeNUnbalancedEnding", TestTrieOffsetRangeNUnbalancedEnding},
+	{"RangeNFullyBetween", TestTrieRangeNFullyBetween},
+	{"OffsetRangeNFullyBetween", TestTrieOffsetRangeNFullyBetween},
+	{"RangeFullyBetween", TestTrieRangeFullyBetween},
+	{"Prefix", TestTriePrefix},
+	{"PrefixN", TestTriePrefixN},
+	{"OffsetPrefixN", TestTrieOffsetPrefixN},
+	{"DeepOffsetPrefixN", TestTrieDeepOffsetPrefixN},
+	{"PrefixNReturnsEarlyIfThereAreMissingValues", TestTriePrefixNReturnsEarlyIfThereAreMissingValues},
+	{"PrefixWithExactMatch", TestTriePrefixWithExactMatch},
+	{"PrefixWithLongTails", TestTriePrefixWithLongTails},
+	{"RangeWithCloseNeighbors", TestTrieRangeWithCloseNeighbors},
+	{"Delete", TestTrieDelete},
+	{"Get", TestTrieGet},
+	{"NilValuesAreScanned", TestTrieNilValuesAreScanned},
+	{"DeleteNilValue", TestTrieDeleteNilValue},
+	{"DeleteReturnsWhetherKeyWasRemoved", TestTrieDeleteReturnsWhetherKeyWasRemoved},
+	{"ScanWithDelete", TestTrieScanWithDelete},
+	{"ReverseRangeN", TestTrieReverseRangeN},
+	{"ReverseRangeNPartiallyOutside", TestTrieReverseRangeNPartiallyOutside},
+	{"ReversePrefixN", TestTrieReversePrefixN},
+	{"ReversePrefixNWithExactMatch", TestTrieReversePrefixNWithExactMatch},
+	{"Len", TestTrieLen},
+	{"CountPrefix", TestTrieCountPrefix},
+	{"CountRange", TestTrieCountRange},
+	{"LongestPrefix", TestTrieLongestPrefix},
+	{"AllPrefixesOf", TestTrieAllPrefixesOf},
+	{"OffsetRangeKV", TestTrieOffsetRangeKV},
+	{"OffsetPrefixKV", TestTrieOffsetPrefixKV},
+	{"ReverseRangeKV", TestTrieReverseRangeKV},
+	{"ReversePrefixKV", TestTrieReversePrefixKV},
+	{"KVMatchesN", TestTrieKVMatchesN},
+	{"ZeroByteKeys", TestTrieZeroByteKeys},
+	{"BinaryKeys", TestTrieBinaryKeys},
+}
+
+func runSuite(t *testing.T, newTrie func() Trie) {
+	defer func(orig func() Trie) {
+		newTestTrie = orig
+	}(newTestTrie)
+
+	newTestTrie = newTrie
+
+	for _, s := range suite {
+		t.Run(s.name, s.test)
+	}
+}
+
 func TestTrieLookup(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -21,7 +94,7 @@ func TestTrieLookup(t *testing.T) {
 }
 
 func TestTrieRangeInclusive(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -41,7 +114,7 @@ func TestTrieRangeInclusive(t *testing.T) {
 }
 
 func TestTrieRangeWithCommonSuffix(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test:2015-04-30:test"), "Hello")
 	trie.Insert([]byte("test:2015-05-01:test"), "Hello")
 	trie.Insert([]byte("test:2015-05-02:test"), "Hello")
@@ -56,7 +129,7 @@ func TestTrieRangeWithCommonSuffix(t *testing.T) {
 }
 
 func TestTrieRangeExclusive(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -68,7 +141,7 @@ func TestTrieRangeExclusive(t *testing.T) {
 }
 
 func TestTrieRangePrefixed(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -88,7 +161,7 @@ func TestTrieRangePrefixed(t *testing.T) {
 }
 
 func TestTrieRangeOutside(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -100,7 +173,7 @@ func TestTrieRangeOutside(t *testing.T) {
 }
 
 func TestTrieRangePartiallyOutside(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -120,7 +193,7 @@ func TestTrieRangePartiallyOutside(t *testing.T) {
 }
 
 func TestTrieOffsetRangeNPartiallyOutside(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -137,7 +210,7 @@ func TestTrieOffsetRangeNPartiallyOutside(t *testing.T) {
 }
 
 func TestTrieRangeUnbalancedEnding(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -157,7 +230,7 @@ func TestTrieRangeUnbalancedEnding(t *testing.T) {
 }
 
 func TestTrieOffsetRangeNUnbalancedEnding(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test1"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -207,7 +280,7 @@ func TestTrieRangeFullyBetween(t *testing.T) {
 }
 
 func TestTriePrefix(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("table2#test1"), "Hello")
 	trie.Insert([]byte("table2#test2"), "World")
 
@@ -229,7 +302,7 @@ func TestTriePrefix(t *testing.T) {
 }
 
 func TestTriePrefixN(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("table2#test1"), "Hello")
 	trie.Insert([]byte("table2#test2"), "World")
 
@@ -247,7 +320,7 @@ func TestTriePrefixN(t *testing.T) {
 }
 
 func TestTrieOffsetPrefixN(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("table2#test1"), "Hello")
 	trie.Insert([]byte("table2#test2"), "World")
 	trie.Insert([]byte("table2#test3"), "Yes")
@@ -282,7 +355,7 @@ func TestTrieOffsetPrefixN(t *testing.T) {
 }
 
 func TestTrieDeepOffsetPrefixN(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("table2#test#doodad:1"), "Hello")
 	trie.Insert([]byte("table2#test#doodad:2"), "World")
 	trie.Insert([]byte("table2#test#tesla:3"), "Yes")
@@ -314,7 +387,7 @@ func TestTrieDeepOffsetPrefixN(t *testing.T) {
 }
 
 func TestTriePrefixNReturnsEarlyIfThereAreMissingValues(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("table2#test1"), "Hello")
 	trie.Insert([]byte("table2#test2"), "World")
 	trie.Insert([]byte("table2#test3"), "Yes")
@@ -343,7 +416,7 @@ func TestTriePrefixNReturnsEarlyIfThereAreMissingValues(t *testing.T) {
 }
 
 func TestTriePrefixWithExactMatch(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 
@@ -365,7 +438,7 @@ func TestTriePrefixWithExactMatch(t *testing.T) {
 }
 
 func TestTriePrefixWithLongTails(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("test"), "Hello")
 	trie.Insert([]byte("test2"), "World")
 	trie.Insert([]byte("test again"), "Once")
@@ -385,7 +458,7 @@ func TestTriePrefixWithLongTails(t *testing.T) {
 }
 
 func TestTrieRangeWithCloseNeighbors(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
 	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
 	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
@@ -406,7 +479,7 @@ func TestTrieRangeWithCloseNeighbors(t *testing.T) {
 }
 
 func TestTrieDelete(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("prefix1:prefix2:2015-05-01"), "Hello")
 	trie.Insert([]byte("prefix1:prefix200:2015-05-01"), "What")
 	trie.Insert([]byte("prefix1:prefix2:2015-05-30"), "Friend")
@@ -425,8 +498,176 @@ func TestTrieDelete(t *testing.T) {
 	}
 }
 
+func TestTrieGet(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test1"), "Hello")
+	trie.Insert([]byte("test2"), nil)
+
+	val, ok := trie.Get([]byte("test1"))
+
+	if !ok || val != "Hello" {
+		t.Fatalf(`Expected "test1" to be "Hello", got %v (present: %v).`, val, ok)
+	}
+
+	val, ok = trie.Get([]byte("test2"))
+
+	if !ok || val != nil {
+		t.Fatalf(`Expected "test2" to be a present nil, got %v (present: %v).`, val, ok)
+	}
+
+	// Intermediate nodes don't count as keys.
+	if trie.Has([]byte("test")) {
+		t.Fatalf(`Expected "test" not to be present.`)
+	}
+
+	if trie.Has([]byte("test3")) {
+		t.Fatalf(`Expected "test3" not to be present.`)
+	}
+}
+
+func TestTrieNilValuesAreScanned(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test1"), nil)
+	trie.Insert([]byte("test2"), "World")
+
+	vals := trie.Prefix([]byte("test"))
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+
+	if _, ok := vals["test1"]; !ok {
+		t.Fatalf(`Expected "test1" to be in the results.`)
+	}
+
+	vals = trie.Range([]byte("test1"), []byte("test2"))
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+}
+
+func TestTrieDeleteNilValue(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test1"), nil)
+	trie.Delete([]byte("test1"))
+
+	if trie.Has([]byte("test1")) {
+		t.Fatalf(`Expected "test1" to be gone.`)
+	}
+
+	if len(trie.Prefix([]byte("test"))) != 0 {
+		t.Fatalf(`Expected nothing left under "test".`)
+	}
+}
+
+func TestTrieDeleteReturnsWhetherKeyWasRemoved(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test1"), "Hello")
+	trie.Insert([]byte("test12"), "World")
+
+	if trie.Delete([]byte("test")) {
+		t.Fatalf(`Expected deleting "test" to return false.`)
+	}
+
+	if trie.Delete([]byte("test123")) {
+		t.Fatalf(`Expected deleting "test123" to return false.`)
+	}
+
+	if !trie.Delete([]byte("test1")) {
+		t.Fatalf(`Expected deleting "test1" to return true.`)
+	}
+
+	if trie.Delete([]byte("test1")) {
+		t.Fatalf(`Expected deleting "test1" twice to return false.`)
+	}
+
+	if trie.Lookup([]byte("test12")) != "World" {
+		t.Fatalf(`Expected "test12" to survive deleting "test1".`)
+	}
+}
+
+func TestTrieDeletePrunesEmptyBranches(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test"), "Hello")
+	trie.Insert([]byte("test:2015-05-01"), "World")
+	trie.Insert([]byte("test:2015-05-02"), "Again")
+
+	trie.Delete([]byte("test:2015-05-01"))
+	trie.Delete([]byte("test:2015-05-02"))
+
+	// Only the nodes for "test" should be left over.
+	if c := countNodes(trie.(*trieImpl)); c != 5 {
+		t.Fatalf(`Expected 5 nodes to be left, got %d.`, c)
+	}
+
+	trie.Delete([]byte("test"))
+
+	if c := countNodes(trie.(*trieImpl)); c != 1 {
+		t.Fatalf(`Expected only the root to be left, got %d nodes.`, c)
+	}
+}
+
+func TestTrieDeletePrefix(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("table2#test1"), "Hello")
+	trie.Insert([]byte("table2#test2"), "World")
+	trie.Insert([]byte("table2"), "Yes")
+	trie.Insert([]byte("table3#test1"), "Hallooo")
+
+	if c := trie.DeletePrefix([]byte("table2#")); c != 2 {
+		t.Fatalf(`Expected to remove 2 keys, removed %d.`, c)
+	}
+
+	if trie.Len() != 2 {
+		t.Fatalf(`Expected length to be 2, got %d.`, trie.Len())
+	}
+
+	if trie.Lookup([]byte("table2")) != "Yes" {
+		t.Fatalf(`Expected "table2" to survive.`)
+	}
+
+	if c := trie.DeletePrefix([]byte("table4")); c != 0 {
+		t.Fatalf(`Expected to remove 0 keys, removed %d.`, c)
+	}
+
+	if c := trie.DeletePrefix([]byte("")); c != 2 {
+		t.Fatalf(`Expected to remove 2 keys, removed %d.`, c)
+	}
+
+	if c := countNodes(trie.(*trieImpl)); c != 1 {
+		t.Fatalf(`Expected only the root to be left, got %d nodes.`, c)
+	}
+}
+
+func TestTrieDeleteRange(t *testing.T) {
+	trie := setupTrie()
+	trie.Insert([]byte("2014090"), "2014090")
+	trie.Insert([]byte("201409055"), "201409055")
+
+	if c := trie.DeleteRange([]byte("20140903"), []byte("20140906")); c != 5 {
+		t.Fatalf(`Expected to remove 5 keys, removed %d.`, c)
+	}
+
+	if trie.Len() != 8 {
+		t.Fatalf(`Expected length to be 8, got %d.`, trie.Len())
+	}
+
+	if c := trie.CountRange([]byte("20140901"), []byte("20140911")); c != 7 {
+		t.Fatalf(`Expected count to be 7, got %d.`, c)
+	}
+
+	if c := trie.DeleteRange([]byte("2014"), []byte("2015")); c != 8 {
+		t.Fatalf(`Expected to remove 8 keys, removed %d.`, c)
+	}
+
+	if c := countNodes(trie.(*trieImpl)); c != 1 {
+		t.Fatalf(`Expected only the root to be left, got %d nodes.`, c)
+	}
+}
+
 func TestTrieScanWithDelete(t *testing.T) {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("table2#test1"), "Hello")
 	trie.Insert([]byte("table2#test2"), "World")
 
@@ -444,8 +685,506 @@ func TestTrieScanWithDelete(t *testing.T) {
 	}
 }
 
+func TestTrieReverseRangeN(t *testing.T) {
+	trie := setupTrie()
+
+	vals := trie.ReverseRangeN([]byte("20140901"), []byte("20140909"), 3)
+
+	if len(vals) != 3 {
+		t.Fatalf(`Expected length of val to be 3, got %d.`, len(vals))
+	}
+
+	for _, k := range []string{"20140907", "20140908", "20140909"} {
+		if _, ok := vals[k]; !ok {
+			t.Logf("%v", vals)
+			t.Fatalf(`Expected "%s" to be in the results.`, k)
+		}
+	}
+}
+
+func TestTrieReverseRangeNPartiallyOutside(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test1"), "Hello")
+	trie.Insert([]byte("test2"), "World")
+	trie.Insert([]byte("test5"), "Outside")
+
+	vals := trie.ReverseRangeN([]byte("test0"), []byte("test4"), -1)
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+
+	if vals["test2"] != "World" {
+		t.Fatalf(`Expected "test2" to be "World", got "%v"`, vals["test2"])
+	}
+}
+
+func TestTrieReversePrefixN(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("table2#test1"), "Hello")
+	trie.Insert([]byte("table2#test2"), "World")
+	trie.Insert([]byte("table2#test3"), "Yes")
+	trie.Insert([]byte("table3#test1"), "Hallooo")
+
+	vals := trie.ReversePrefixN([]byte("table2"), 2)
+
+	if len(vals) != 2 {
+		t.Logf("%v", vals)
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+
+	if vals["table2#test3"] != "Yes" {
+		t.Fatalf(`Expected "table2#test3" to be "Yes", got %s.`, vals["table2#test3"])
+	}
+
+	if vals["table2#test2"] != "World" {
+		t.Fatalf(`Expected "table2#test2" to be "World", got %s.`, vals["table2#test2"])
+	}
+}
+
+func TestTrieReversePrefixNWithExactMatch(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("test"), "Hello")
+	trie.Insert([]byte("test2"), "World")
+
+	vals := trie.ReversePrefixN([]byte("test"), -1)
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+
+	vals = trie.ReversePrefixN([]byte("nope"), -1)
+
+	if len(vals) != 0 {
+		t.Fatalf(`Expected length of val to be 0, got %d.`, len(vals))
+	}
+}
+
+func TestTrieLen(t *testing.T) {
+	trie := newTestTrie()
+
+	if trie.Len() != 0 {
+		t.Fatalf(`Expected length to be 0, got %d.`, trie.Len())
+	}
+
+	trie.Insert([]byte("test1"), "Hello")
+	trie.Insert([]byte("test2"), "World")
+	trie.Insert([]byte("test"), "Again")
+
+	// Overwriting a key shouldn't count twice.
+	trie.Insert([]byte("test2"), "Friend")
+
+	if trie.Len() != 3 {
+		t.Fatalf(`Expected length to be 3, got %d.`, trie.Len())
+	}
+
+	if trie.Count() != 3 {
+		t.Fatalf(`Expected count to be 3, got %d.`, trie.Count())
+	}
+
+	trie.Delete([]byte("test1"))
+
+	// Deleting something that isn't there shouldn't change anything.
+	trie.Delete([]byte("test1"))
+	trie.Delete([]byte("tes"))
+
+	if trie.Len() != 2 {
+		t.Fatalf(`Expected length to be 2, got %d.`, trie.Len())
+	}
+}
+
+func TestTrieCountPrefix(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("table2#test1"), "Hello")
+	trie.Insert([]byte("table2#test2"), "World")
+	trie.Insert([]byte("table2"), "Yes")
+	trie.Insert([]byte("table3#test1"), "Hallooo")
+
+	if c := trie.CountPrefix([]byte("table2")); c != 3 {
+		t.Fatalf(`Expected count to be 3, got %d.`, c)
+	}
+
+	if c := trie.CountPrefix([]byte("table2#")); c != 2 {
+		t.Fatalf(`Expected count to be 2, got %d.`, c)
+	}
+
+	if c := trie.CountPrefix([]byte("")); c != 4 {
+		t.Fatalf(`Expected count to be 4, got %d.`, c)
+	}
+
+	if c := trie.CountPrefix([]byte("table4")); c != 0 {
+		t.Fatalf(`Expected count to be 0, got %d.`, c)
+	}
+}
+
+func TestTrieCountRange(t *testing.T) {
+	trie := setupTrie()
+
+	if c := trie.CountRange([]byte("20140901"), []byte("20140911")); c != 11 {
+		t.Fatalf(`Expected count to be 11, got %d.`, c)
+	}
+
+	if c := trie.CountRange([]byte("20140903"), []byte("20140905")); c != 3 {
+		t.Fatalf(`Expected count to be 3, got %d.`, c)
+	}
+
+	if c := trie.CountRange([]byte("2014090"), []byte("201409055")); c != 5 {
+		t.Fatalf(`Expected count to be 5, got %d.`, c)
+	}
+
+	if c := trie.CountRange([]byte("20140905"), []byte("20140901")); c != 0 {
+		t.Fatalf(`Expected count to be 0, got %d.`, c)
+	}
+
+	trie.Delete([]byte("20140904"))
+
+	if c := trie.CountRange([]byte("20140903"), []byte("20140905")); c != 2 {
+		t.Fatalf(`Expected count to be 2, got %d.`, c)
+	}
+}
+
+func TestTrieLongestPrefix(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("10."), "default")
+	trie.Insert([]byte("10.1."), "office")
+	trie.Insert([]byte("10.1.2."), "lab")
+
+	key, val, ok := trie.LongestPrefix([]byte("10.1.2.7"))
+
+	if !ok || string(key) != "10.1.2." || val != "lab" {
+		t.Fatalf(`Expected "10.1.2." to match with "lab", got %q and %v.`, key, val)
+	}
+
+	key, val, ok = trie.LongestPrefix([]byte("10.1.3.7"))
+
+	if !ok || string(key) != "10.1." || val != "office" {
+		t.Fatalf(`Expected "10.1." to match with "office", got %q and %v.`, key, val)
+	}
+
+	key, _, ok = trie.LongestPrefix([]byte("10.1."))
+
+	if !ok || string(key) != "10.1." {
+		t.Fatalf(`Expected an exact match for "10.1.", got %q.`, key)
+	}
+
+	if _, _, ok := trie.LongestPrefix([]byte("192.168.0.1")); ok {
+		t.Fatalf(`Expected nothing to match "192.168.0.1".`)
+	}
+
+	if _, _, ok := trie.LongestPrefix([]byte("10")); ok {
+		t.Fatalf(`Expected nothing to match "10".`)
+	}
+
+	trie.Insert([]byte(""), "everything")
+	key, val, ok = trie.LongestPrefix([]byte("192.168.0.1"))
+
+	if !ok || len(key) != 0 || val != "everything" {
+		t.Fatalf(`Expected the empty key to match, got %q and %v.`, key, val)
+	}
+}
+
+func TestTrieAllPrefixesOf(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("config"), 1)
+	trie.Insert([]byte("config.db"), 2)
+	trie.Insert([]byte("config.db.host"), 3)
+	trie.Insert([]byte("config.dbx"), 4)
+
+	vals := trie.AllPrefixesOf([]byte("config.db.host.name"))
+
+	if len(vals) != 3 {
+		t.Fatalf(`Expected length of vals to be 3, got %d.`, len(vals))
+	}
+
+	if vals["config"] != 1 || vals["config.db"] != 2 || vals["config.db.host"] != 3 {
+		t.Fatalf(`Expected every prefix with its value, got %v.`, vals)
+	}
+
+	if vals := trie.AllPrefixesOf([]byte("conf")); len(vals) != 0 {
+		t.Fatalf(`Expected no prefixes of "conf", got %v.`, vals)
+	}
+}
+
+// kvKeys joins up the keys in kvs so they're easy to compare.
+func kvKeys(kvs []KV) string {
+	keys := make([]string, 0, len(kvs))
+
+	for _, kv := range kvs {
+		keys = append(keys, string(kv.Key))
+	}
+
+	return strings.Join(keys, ",")
+}
+
+func TestTrieOffsetRangeKV(t *testing.T) {
+	trie := setupTrie()
+	kvs := trie.OffsetRangeKV([]byte{}, []byte("20140903"), []byte("20140912"), -1)
+
+	if keys := kvKeys(kvs); keys != "20140903,20140904,20140905,20140906,20140907,20140908,20140909,20140910,20140911" {
+		t.Fatalf(`Expected the keys in order, got %s.`, keys)
+	}
+
+	if kvs[0].Value != "20140903" {
+		t.Fatalf(`Expected "20140903" to be "20140903", got %v.`, kvs[0].Value)
+	}
+
+	kvs = trie.OffsetRangeKV([]byte("20140905"), []byte("20140903"), []byte("20140912"), 3)
+
+	if keys := kvKeys(kvs); keys != "20140906,20140907,20140908" {
+		t.Fatalf(`Expected the 3 keys after the offset, got %s.`, keys)
+	}
+}
+
+func TestTrieOffsetPrefixKV(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("table2#test3"), "Yes")
+	trie.Insert([]byte("table2#test1"), "Hello")
+	trie.Insert([]byte("table2#test2"), "World")
+	trie.Insert([]byte("table2#test10"), "Again")
+	trie.Insert([]byte("table2#test4"), "Blah")
+	trie.Insert([]byte("table3#test2"), "Hallooo")
+
+	kvs := trie.OffsetPrefixKV([]byte{}, []byte("table2"), 3)
+
+	if keys := kvKeys(kvs); keys != "table2#test1,table2#test10,table2#test2" {
+		t.Fatalf(`Expected the first 3 keys in order, got %s.`, keys)
+	}
+
+	if kvs[1].Value != "Again" {
+		t.Fatalf(`Expected "table2#test10" to be "Again", got %v.`, kvs[1].Value)
+	}
+
+	// The last key makes for the offset of the next page.
+	kvs = trie.OffsetPrefixKV(kvs[2].Key, []byte("table2"), 3)
+
+	if keys := kvKeys(kvs); keys != "table2#test3,table2#test4" {
+		t.Fatalf(`Expected the next 2 keys, got %s.`, keys)
+	}
+}
+
+func TestTrieReverseRangeKV(t *testing.T) {
+	trie := setupTrie()
+	kvs := trie.ReverseRangeKV([]byte("20140903"), []byte("20140906"), -1)
+
+	if keys := kvKeys(kvs); keys != "20140906,20140905,20140904,20140903" {
+		t.Fatalf(`Expected the keys last first, got %s.`, keys)
+	}
+
+	if kvs := trie.ReverseRangeKV([]byte("20140903"), []byte("20140906"), 2); kvKeys(kvs) != "20140906,20140905" {
+		t.Fatalf(`Expected the last 2 keys, got %s.`, kvKeys(kvs))
+	}
+}
+
+func TestTrieReversePrefixKV(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte("ab"), 1)
+	trie.Insert([]byte("abc"), 2)
+	trie.Insert([]byte("abd"), 3)
+	trie.Insert([]byte("ac"), 4)
+
+	kvs := trie.ReversePrefixKV([]byte("ab"), -1)
+
+	if keys := kvKeys(kvs); keys != "abd,abc,ab" {
+		t.Fatalf(`Expected the keys last first, got %s.`, keys)
+	}
+
+	if kvs[2].Value != 1 {
+		t.Fatalf(`Expected "ab" to be 1, got %v.`, kvs[2].Value)
+	}
+
+	if kvs := trie.ReversePrefixKV([]byte("x"), -1); len(kvs) != 0 {
+		t.Fatalf(`Expected nothing, got %s.`, kvKeys(kvs))
+	}
+}
+
+// The KV versions should always find the same things as the map versions.
+func TestTrieKVMatchesN(t *testing.T) {
+	trie := newTestTrie()
+
+	for _, key := range generateKeys(2, "") {
+		trie.Insert([]byte(key), key)
+	}
+
+	// Forwards the keys should keep going up, and backwards down.
+	same := func(kvs []KV, vals map[string]interface{}, dir int) {
+		if len(kvs) != len(vals) {
+			t.Fatalf(`Expected %d keys, got %d.`, len(vals), len(kvs))
+		}
+
+		for i, kv := range kvs {
+			if vals[string(kv.Key)] != kv.Value {
+				t.Fatalf(`Expected %q to be %v, got %v.`, kv.Key, vals[string(kv.Key)], kv.Value)
+			}
+
+			if i > 0 && bytes.Compare(kv.Key, kvs[i-1].Key) != dir {
+				t.Fatalf(`Expected %q to come after %q.`, kv.Key, kvs[i-1].Key)
+			}
+		}
+	}
+
+	for _, offset := range []string{"", "b", "bc", "bcd", "cz"} {
+		for _, n := range []int{-1, 0, 1, 5, 50} {
+			same(trie.OffsetRangeKV([]byte(offset), []byte("ab"), []byte("dd"), n), trie.OffsetRangeN([]byte(offset), []byte("ab"), []byte("dd"), n), 1)
+			same(trie.OffsetPrefixKV([]byte(offset), []byte("b"), n), trie.OffsetPrefixN([]byte(offset), []byte("b"), n), 1)
+			same(trie.ReverseRangeKV([]byte("ab"), []byte("dd"), n), trie.ReverseRangeN([]byte("ab"), []byte("dd"), n), -1)
+			same(trie.ReversePrefixKV([]byte("b"), n), trie.ReversePrefixN([]byte("b"), n), -1)
+		}
+	}
+}
+
+func TestTrieZeroByteKeys(t *testing.T) {
+	trie := newTestTrie()
+	trie.Insert([]byte{0x00}, "zero")
+	trie.Insert([]byte{0x00, 0x00}, "zero zero")
+	trie.Insert([]byte{0x00, 0x01}, "zero one")
+	trie.Insert([]byte{0x01, 0x00}, "one zero")
+	trie.Insert([]byte("a\x00b"), "a zero b")
+
+	vals := trie.Range([]byte{0x00}, []byte{0x00, 0xFF})
+
+	if len(vals) != 3 {
+		t.Fatalf(`Expected length of vals to be 3, got %d.`, len(vals))
+	}
+
+	if vals["\x00\x01"] != "zero one" {
+		t.Fatalf(`Expected "\x00\x01" to be "zero one", got %v.`, vals["\x00\x01"])
+	}
+
+	if vals := trie.Range([]byte{0x00, 0x01}, []byte{0x01, 0x00}); len(vals) != 2 || vals["\x01\x00"] != "one zero" {
+		t.Fatalf(`Expected "\x00\x01" and "\x01\x00", got %v.`, vals)
+	}
+
+	if vals := trie.Prefix([]byte{0x00}); len(vals) != 3 {
+		t.Fatalf(`Expected length of vals to be 3, got %d.`, len(vals))
+	}
+
+	if vals := trie.Prefix([]byte("a\x00")); len(vals) != 1 || vals["a\x00b"] != "a zero b" {
+		t.Fatalf(`Expected only "a\x00b", got %v.`, vals)
+	}
+
+	if vals := trie.OffsetRangeN([]byte{0x00, 0x01}, []byte{0x00, 0x01}, []byte{0xFF}, -1); len(vals) != 2 || vals["a\x00b"] != "a zero b" {
+		t.Fatalf(`Expected "\x01\x00" and "a\x00b" after the offset, got %v.`, vals)
+	}
+}
+
+// randomBinaryKey makes keys out of a few bytes, including both ends, so that
+// they run into each other a lot.
+func randomBinaryKey(rnd *rand.Rand) []byte {
+	key := make([]byte, rnd.Intn(5))
+
+	for i := range key {
+		key[i] = []byte{0x00, 0x01, 'a', 0xFE, 0xFF}[rnd.Intn(5)]
+	}
+
+	return key
+}
+
+// Checks every kind of read against going through all the keys by hand.
+func TestTrieBinaryKeys(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	trie := newTestTrie()
+	expected := make(map[string]interface{})
+
+	for i := 0; i < 300; i++ {
+		key := randomBinaryKey(rnd)
+
+		if rnd.Intn(4) == 0 {
+			trie.Delete(key)
+			delete(expected, string(key))
+		} else {
+			trie.Insert(key, i)
+			expected[string(key)] = i
+		}
+	}
+
+	sorted := make([]string, 0, len(expected))
+
+	for key := range expected {
+		sorted = append(sorted, key)
+	}
+
+	sort.Strings(sorted)
+
+	// Everything from keys that keep says to, in order, after the offset.
+	want := func(offset []byte, n int, keep func(key string) bool) []KV {
+		res := make([]KV, 0)
+
+		for _, key := range sorted {
+			if len(res) != n && keep(key) && isOffsetLesser(offset, []byte(key)) {
+				res = append(res, KV{[]byte(key), expected[key]})
+			}
+		}
+
+		return res
+	}
+
+	same := func(what string, got map[string]interface{}, kvs []KV) {
+		if len(got) != len(kvs) {
+			t.Fatalf(`Expected %d keys from %s, got %d.`, len(kvs), what, len(got))
+		}
+
+		for _, kv := range kvs {
+			if val, ok := got[string(kv.Key)]; !ok || val != kv.Value {
+				t.Fatalf(`Expected %q from %s to be %v, got %v.`, kv.Key, what, kv.Value, val)
+			}
+		}
+	}
+
+	for i := 0; i < 300; i++ {
+		start, end, prefix := randomBinaryKey(rnd), randomBinaryKey(rnd), randomBinaryKey(rnd)
+		offset, n := []byte{}, rnd.Intn(10)-1
+
+		if rnd.Intn(2) == 0 {
+			offset = randomBinaryKey(rnd)
+		}
+
+		inRange := func(key string) bool {
+			return key >= string(start) && key <= string(end)
+		}
+
+		hasPrefix := func(key string) bool {
+			return strings.HasPrefix(key, string(prefix))
+		}
+
+		same("Range", trie.Range(start, end), want([]byte{}, -1, inRange))
+		same("OffsetRangeN", trie.OffsetRangeN(offset, start, end, n), want(offset, n, inRange))
+		same("Prefix", trie.Prefix(prefix), want([]byte{}, -1, hasPrefix))
+		same("OffsetPrefixN", trie.OffsetPrefixN(offset, prefix, n), want(offset, n, hasPrefix))
+
+		if kvs := want(offset, n, inRange); kvKeys(trie.OffsetRangeKV(offset, start, end, n)) != kvKeys(kvs) {
+			t.Fatalf(`Expected OffsetRangeKV to find %q, got %q.`, kvKeys(kvs), kvKeys(trie.OffsetRangeKV(offset, start, end, n)))
+		}
+
+		if c := len(want([]byte{}, -1, inRange)); trie.CountRange(start, end) != c {
+			t.Fatalf(`Expected CountRange to be %d, got %d.`, c, trie.CountRange(start, end))
+		}
+
+		if c := len(want([]byte{}, -1, hasPrefix)); trie.CountPrefix(prefix) != c {
+			t.Fatalf(`Expected CountPrefix to be %d, got %d.`, c, trie.CountPrefix(prefix))
+		}
+
+		if val, ok := trie.Get(start); val != expected[string(start)] || ok != (expected[string(start)] != nil) {
+			t.Fatalf(`Expected %q to be %v, got %v.`, start, expected[string(start)], val)
+		}
+
+		// Reverse ones take the n from the other end.
+		reversed := func(kvs []KV) []KV {
+			res := make([]KV, 0)
+
+			for i := len(kvs) - 1; i >= 0 && len(res) != n; i-- {
+				res = append(res, kvs[i])
+			}
+
+			return res
+		}
+
+		same("ReverseRangeN", trie.ReverseRangeN(start, end, n), reversed(want([]byte{}, -1, inRange)))
+		same("ReversePrefixN", trie.ReversePrefixN(prefix, n), reversed(want([]byte{}, -1, hasPrefix)))
+	}
+}
+
 func BenchmarkTrieLookup(b *testing.B) {
-	trie := New()
+	trie := newTestTrie()
 	keys := generateKeys(6, "")
 
 	for i, k := range keys {
@@ -460,7 +1199,7 @@ func BenchmarkTrieLookup(b *testing.B) {
 }
 
 func BenchmarkTriePrefix(b *testing.B) {
-	trie := New()
+	trie := newTestTrie()
 	keys := generateKeys(6, "")
 
 	for i, k := range keys {
@@ -492,8 +1231,103 @@ func generateKeys(level int, prefix string) []string {
 	return keys
 }
 
+// testMatchesTrie throws a bunch of random inserts and deletes at trie, checking
+// that it ends up agreeing with the default backend every step of the way.
+func testMatchesTrie(t *testing.T, trie Trie, alphabet string) {
+	rnd := rand.New(rand.NewSource(1))
+	expected := New()
+
+	randomKey := func() []byte {
+		key := make([]byte, rnd.Intn(6))
+
+		for i := range key {
+			key[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+
+		return key
+	}
+
+	for i := 0; i < 2000; i++ {
+		key := randomKey()
+
+		if rnd.Intn(3) == 0 {
+			if expected.Delete(key) != trie.Delete(key) {
+				t.Fatalf(`Expected deleting "%s" to agree.`, key)
+			}
+		} else {
+			expected.Insert(key, i)
+			trie.Insert(key, i)
+		}
+
+		if expected.Len() != trie.Len() {
+			t.Fatalf(`Expected length to be %d, got %d.`, expected.Len(), trie.Len())
+		}
+
+		seek := randomKey()
+
+		for _, reverse := range []bool{false, true} {
+			var want, got Iterator
+
+			if reverse {
+				want, got = expected.ReverseIterator(), trie.ReverseIterator()
+			} else {
+				want, got = expected.Iterator(), trie.Iterator()
+			}
+
+			got.Seek(seek)
+
+			for want.Seek(seek); want.Valid(); want.Next() {
+				if !got.Valid() || string(got.Key()) != string(want.Key()) || got.Value() != want.Value() {
+					t.Fatalf(`Expected seek to "%s" to find "%s", got "%s".`, seek, want.Key(), got.Key())
+				}
+
+				got.Next()
+			}
+
+			if got.Valid() {
+				t.Fatalf(`Expected iterator to be exhausted, was at "%s".`, got.Key())
+			}
+		}
+
+		if c := trie.CountPrefix(seek); c != expected.CountPrefix(seek) {
+			t.Fatalf(`Expected count of "%s" to be %d, got %d.`, seek, expected.CountPrefix(seek), c)
+		}
+	}
+}
+
+// backends has a constructor for every kind of Trie.
+var backends = []struct {
+	name    string
+	newTrie func() Trie
+}{
+	{"Trie", func() Trie { return New() }},
+	{"Radix", NewRadix},
+	{"ART", NewART},
+	{"Concurrent", NewConcurrent},
+}
+
+// allBackends runs test against an empty trie from every backend, handing each
+// of them the same random numbers.
+func allBackends(t *testing.T, test func(t *testing.T, trie Trie, rnd *rand.Rand)) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			test(t, b.newTrie(), rand.New(rand.NewSource(1)))
+		})
+	}
+}
+
+func countNodes(trie *trieImpl) int {
+	count := 1
+
+	for _, child := range trie.children {
+		count += countNodes(child)
+	}
+
+	return count
+}
+
 func setupTrie() Trie {
-	trie := New()
+	trie := newTestTrie()
 	trie.Insert([]byte("20140901"), "20140901")
 	trie.Insert([]byte("20140902"), "20140902")
 	trie.Insert([]byte("20140903"), "20140903")
@@ -0,0 +1,167 @@
+package trie
+
+import (
+	"errors"
+)
+
+// ErrTxnClosed is returned by Commit and Rollback on a Txn that's already been
+// committed or rolled back. The rest of Txn's methods panic with it instead.
+var ErrTxnClosed = errors.New("trie: transaction has already been committed or rolled back")
+
+// A Txn groups up a bunch of writes so that they all land in the trie at once
+// when it's committed, or not at all if it's rolled back. Reads through the
+// Txn see its own writes on top of whatever's in the trie.
+//
+// The writes are kept in a trie of their own until Commit. Once a Txn has been
+// committed or rolled back it can't be used again: Commit and Rollback return
+// ErrTxnClosed, and every other method panics with it, since using a closed
+// Txn is a bug in the caller rather than something to handle.
+type Txn struct {
+	base Reader
+
+	// The keys this transaction has written to. Deletes are stored too, so
+	// that we know to hide the key in base.
+	pending *trieImpl
+
+	// Applies everything in pending to the trie.
+	commit func(self *Txn)
+	closed bool
+}
+
+type txnWrite struct {
+	value   interface{}
+	weight  float64
+	deleted bool
+}
+
+func newTxn(base Reader, commit func(self *Txn)) *Txn {
+	return &Txn{base: base, pending: New().(*trieImpl), commit: commit}
+}
+
+func (self *Txn) check() {
+	if self.closed {
+		panic(ErrTxnClosed)
+	}
+}
+
+// each hands every write in the transaction to fn, in key order.
+func (self *Txn) each(fn func(key []byte, val interface{}, weight float64, deleted bool)) {
+	for it := self.pending.Iterator(); it.Valid(); it.Next() {
+		w := it.Value().(txnWrite)
+		fn(it.Key(), w.value, w.weight, w.deleted)
+	}
+}
+
+// Insert stores val at key once the transaction is committed. It panics if the
+// transaction is closed.
+func (self *Txn) Insert(key []byte, val interface{}) {
+	self.InsertWeighted(key, val, 0)
+}
+
+// InsertWeighted stores val at key with a weight for TopK to rank it by, once
+// the transaction is committed. It panics if the transaction is closed.
+func (self *Txn) InsertWeighted(key []byte, val interface{}, weight float64) {
+	self.check()
+	self.pending.Insert(key, txnWrite{value: val, weight: weight})
+}
+
+// Delete removes key, returning true if it was there as far as the transaction
+// can tell. It panics if the transaction is closed.
+func (self *Txn) Delete(key []byte) bool {
+	self.check()
+
+	existed := self.Has(key)
+	self.pending.Insert(key, txnWrite{deleted: true})
+	return existed
+}
+
+// Get returns the value at key as the transaction sees it, and whether or not
+// there was one. It panics if the transaction is closed.
+func (self *Txn) Get(key []byte) (interface{}, bool) {
+	self.check()
+
+	if val, ok := self.pending.Get(key); ok {
+		w := val.(txnWrite)
+		return w.value, !w.deleted
+	}
+
+	return self.base.Get(key)
+}
+
+// Lookup is Get without the bool. It panics if the transaction is closed.
+func (self *Txn) Lookup(key []byte) interface{} {
+	val, _ := self.Get(key)
+	return val
+}
+
+// Has returns true if key is there as far as the transaction can tell. It
+// panics if the transaction is closed.
+func (self *Txn) Has(key []byte) bool {
+	_, ok := self.Get(key)
+	return ok
+}
+
+// Commit applies all of the transaction's writes to the trie. Nobody reading
+// the trie will see some of them without the rest, with the usual caveat that
+// only tries from NewConcurrent can be read while they're being written to.
+// It returns ErrTxnClosed if the transaction has already been committed or
+// rolled back.
+func (self *Txn) Commit() error {
+	if self.closed {
+		return ErrTxnClosed
+	}
+
+	self.commit(self)
+	self.closed = true
+	self.pending = nil
+	return nil
+}
+
+// Rollback throws away all of the transaction's writes. It returns
+// ErrTxnClosed if the transaction has already been committed or rolled back.
+func (self *Txn) Rollback() error {
+	if self.closed {
+		return ErrTxnClosed
+	}
+
+	self.closed = true
+	self.pending = nil
+	return nil
+}
+
+// Begin starts a transaction on the trie.
+func (self *trieImpl) Begin() *Txn {
+	return newTxn(self, func(txn *Txn) {
+		txn.each(func(key []byte, val interface{}, weight float64, deleted bool) {
+			if deleted {
+				self.Delete(key)
+			} else {
+				self.InsertWeighted(key, val, weight)
+			}
+		})
+	})
+}
+
+// Backends that can be read while they're being written to implement committer
+// to apply a transaction all in one go.
+type committer interface {
+	commit(txn *Txn)
+}
+
+// Begin starts a transaction on the trie.
+func (self *ordered) Begin() *Txn {
+	return newTxn(self, func(txn *Txn) {
+		if c, ok := self.store.(committer); ok {
+			c.commit(txn)
+			return
+		}
+
+		txn.each(func(key []byte, val interface{}, weight float64, deleted bool) {
+			if deleted {
+				self.Delete(key)
+			} else {
+				self.InsertWeighted(key, val, weight)
+			}
+		})
+	})
+}
@@ -0,0 +1,239 @@
+package trie
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestTxnReadYourWrites(t *testing.T) {
+	trie := setupTrie()
+	txn := trie.Begin()
+
+	txn.Insert([]byte("20140912"), "20140912")
+	txn.Insert([]byte("20140901"), "Changed")
+
+	if !txn.Delete([]byte("20140905")) {
+		t.Fatalf(`Expected "20140905" to have been there.`)
+	}
+
+	if txn.Delete([]byte("20140905")) {
+		t.Fatalf(`Expected "20140905" to be gone after deleting it.`)
+	}
+
+	if txn.Lookup([]byte("20140912")) != "20140912" || txn.Lookup([]byte("20140901")) != "Changed" {
+		t.Fatalf(`Expected the transaction to see its own writes.`)
+	}
+
+	if txn.Has([]byte("20140905")) {
+		t.Fatalf(`Expected the transaction not to see "20140905".`)
+	}
+
+	if txn.Lookup([]byte("20140902")) != "20140902" {
+		t.Fatalf(`Expected the transaction to see keys it didn't touch.`)
+	}
+
+	if trie.Has([]byte("20140912")) || !trie.Has([]byte("20140905")) || trie.Lookup([]byte("20140901")) != "20140901" {
+		t.Fatalf(`Expected the trie to be unchanged before Commit.`)
+	}
+}
+
+func TestTxnCommit(t *testing.T) {
+	trie := setupTrie()
+	txn := trie.Begin()
+
+	txn.Insert([]byte("20140912"), "20140912")
+	txn.Delete([]byte("20140905"))
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if !trie.Has([]byte("20140912")) || trie.Has([]byte("20140905")) || trie.Len() != 11 {
+		t.Fatalf(`Expected the writes to have been applied.`)
+	}
+
+	if err := txn.Commit(); err != ErrTxnClosed {
+		t.Fatalf(`Expected ErrTxnClosed, got %v.`, err)
+	}
+
+	if err := txn.Rollback(); err != ErrTxnClosed {
+		t.Fatalf(`Expected ErrTxnClosed, got %v.`, err)
+	}
+}
+
+func TestTxnRollback(t *testing.T) {
+	trie := setupTrie()
+	txn := trie.Begin()
+
+	txn.Insert([]byte("20140912"), "20140912")
+	txn.Delete([]byte("20140905"))
+
+	if err := txn.Rollback(); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	if trie.Has([]byte("20140912")) || !trie.Has([]byte("20140905")) {
+		t.Fatalf(`Expected the writes to have been thrown away.`)
+	}
+
+	defer func() {
+		if recover() != ErrTxnClosed {
+			t.Fatalf(`Expected writing to a closed transaction to panic.`)
+		}
+	}()
+
+	txn.Insert([]byte("20140913"), "20140913")
+}
+
+// expectTxnClosed fails the test unless fn panics with ErrTxnClosed.
+func expectTxnClosed(t *testing.T, name string, fn func()) {
+	defer func() {
+		if r := recover(); r != ErrTxnClosed {
+			t.Fatalf(`Expected %s on a closed transaction to panic with ErrTxnClosed, got %v.`, name, r)
+		}
+	}()
+
+	fn()
+}
+
+func TestTxnClosed(t *testing.T) {
+	trie := setupTrie()
+	txn := trie.Begin()
+	txn.Insert([]byte("20140912"), "20140912")
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf(`Expected no error, got %v.`, err)
+	}
+
+	key := []byte("20140912")
+
+	expectTxnClosed(t, "Insert", func() { txn.Insert(key, "again") })
+	expectTxnClosed(t, "InsertWeighted", func() { txn.InsertWeighted(key, "again", 1) })
+	expectTxnClosed(t, "Delete", func() { txn.Delete(key) })
+	expectTxnClosed(t, "Get", func() { txn.Get(key) })
+	expectTxnClosed(t, "Lookup", func() { txn.Lookup(key) })
+	expectTxnClosed(t, "Has", func() { txn.Has(key) })
+
+	if err := txn.Commit(); err != ErrTxnClosed {
+		t.Fatalf(`Expected ErrTxnClosed, got %v.`, err)
+	}
+
+	if err := txn.Rollback(); err != ErrTxnClosed {
+		t.Fatalf(`Expected ErrTxnClosed, got %v.`, err)
+	}
+
+	if val := trie.Lookup(key); val != "20140912" {
+		t.Fatalf(`Expected the closed transaction not to have touched the trie, got %v.`, val)
+	}
+}
+
+func TestTxnOtherBackends(t *testing.T) {
+	for _, newTrie := range []func() Trie{NewRadix, NewART, NewConcurrent} {
+		trie := newTrie()
+		trie.Insert([]byte("test1"), "Hello")
+
+		txn := trie.Begin()
+		txn.Insert([]byte("test2"), "World")
+		txn.Delete([]byte("test1"))
+
+		if trie.Len() != 1 || !txn.Has([]byte("test2")) || txn.Has([]byte("test1")) {
+			t.Fatalf(`Expected only the transaction to see its writes.`)
+		}
+
+		txn.Commit()
+
+		if trie.Len() != 1 || trie.Lookup([]byte("test2")) != "World" {
+			t.Fatalf(`Expected the writes to have been applied.`)
+		}
+	}
+}
+
+// Run this one with -race. Writers put in and take out whole records at a
+// time, and readers should only ever see whole records.
+func TestTxnConcurrentAtomic(t *testing.T) {
+	trie := NewConcurrent()
+	columns, records := 5, 200
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+	var wg, rg sync.WaitGroup
+
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := w; i < records; i += 2 {
+				txn := trie.Begin()
+
+				for c := 0; c < columns; c++ {
+					txn.Insert([]byte(fmt.Sprintf("table2#%03d#%d", i, c)), c)
+				}
+
+				txn.Commit()
+
+				if i%3 == 0 {
+					txn = trie.Begin()
+
+					for c := 0; c < columns; c++ {
+						txn.Delete([]byte(fmt.Sprintf("table2#%03d#%d", i, c)))
+					}
+
+					txn.Commit()
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < 2; r++ {
+		rg.Add(1)
+
+		go func(r int) {
+			defer rg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if n := len(trie.Prefix([]byte("table2#"))); n%columns != 0 {
+					errs <- fmt.Errorf(`Expected whole records, got %d keys.`, n)
+					return
+				}
+
+				snap := trie.Snapshot()
+				n := snap.CountPrefix([]byte("table2#"))
+				snap.Release()
+
+				if n%columns != 0 {
+					errs <- fmt.Errorf(`Expected whole records in the snapshot, got %d keys.`, n)
+					return
+				}
+			}
+		}(r)
+	}
+
+	wg.Wait()
+	close(done)
+	rg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	expected := 0
+
+	for i := 0; i < records; i++ {
+		if i%3 != 0 {
+			expected += columns
+		}
+	}
+
+	if trie.Len() != expected {
+		t.Fatalf(`Expected a length of %d, got %d.`, expected, trie.Len())
+	}
+}
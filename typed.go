@@ -0,0 +1,298 @@
+package trie
+
+// Key is the set of types that can be used as keys in a Typed trie.
+type Key interface {
+	~string | ~[]byte
+}
+
+// Typed is a Trie that knows what its keys and values are, so callers don't
+// have to type assert everything that comes out of it. It's a thin layer over
+// a regular Trie, which is available via Trie if you need to get at it.
+//
+// Everything that hands back more than one key does it as a slice in key
+// order, since []byte keys can't go in a map. Fuzzy, Match, Regexp, Snapshot,
+// Begin and saving and loading aren't here, so use Trie for those.
+type Typed[K Key, V any] struct {
+	trie Trie
+}
+
+// A TypedKV is a key and its value out of a Typed trie.
+type TypedKV[K Key, V any] struct {
+	Key   K
+	Value V
+}
+
+// A TypedCompletion is one of the results from Typed's TopK.
+type TypedCompletion[K Key, V any] struct {
+	Key    K
+	Value  V
+	Weight float64
+}
+
+// A TypedPage is one page of results from Typed's RangePage or PrefixPage.
+type TypedPage[K Key, V any] struct {
+	Items  []TypedKV[K, V]
+	Cursor string
+}
+
+// NewTyped returns an empty Typed trie, built by New with the same options.
+func NewTyped[K Key, V any](opts ...Option) *Typed[K, V] {
+	return NewTypedFrom[K, V](New(opts...))
+}
+
+// NewTypedFrom wraps a Trie you already have. Everything in it has to be a V,
+// the same as with Trie.
+func NewTypedFrom[K Key, V any](trie Trie) *Typed[K, V] {
+	return &Typed[K, V]{trie: trie}
+}
+
+// typedValue converts val to a V. A stored nil won't assert to an interface
+// type, so we hand back the zero value for it instead.
+func typedValue[V any](val interface{}) V {
+	if val == nil {
+		var zero V
+		return zero
+	}
+
+	return val.(V)
+}
+
+func typedKVs[K Key, V any](kvs []KV) []TypedKV[K, V] {
+	res := make([]TypedKV[K, V], len(kvs))
+
+	for i, kv := range kvs {
+		res[i] = TypedKV[K, V]{K(kv.Key), typedValue[V](kv.Value)}
+	}
+
+	return res
+}
+
+// typedEntry is for the reads that hand back a single key, value and whether
+// or not they found anything.
+func typedEntry[K Key, V any](key []byte, val interface{}, ok bool) (K, V, bool) {
+	return K(key), typedValue[V](val), ok
+}
+
+// Trie returns the untyped trie underneath. Anything inserted into it has to be
+// a V, otherwise the typed methods will panic when they come across it.
+func (self *Typed[K, V]) Trie() Trie {
+	return self.trie
+}
+
+func (self *Typed[K, V]) Insert(key K, val V) {
+	self.trie.Insert([]byte(key), val)
+}
+
+// InsertWeighted stores val at key with a weight for TopK to rank it by.
+func (self *Typed[K, V]) InsertWeighted(key K, val V, weight float64) {
+	self.trie.InsertWeighted([]byte(key), val, weight)
+}
+
+// Lookup returns the value stored at key, or the zero value if there isn't
+// one. Use Get if you need to tell the two apart.
+func (self *Typed[K, V]) Lookup(key K) V {
+	val, _ := self.Get(key)
+	return val
+}
+
+// Get returns the value stored at key, and whether or not there was one.
+func (self *Typed[K, V]) Get(key K) (V, bool) {
+	val, ok := self.trie.Get([]byte(key))
+	return typedValue[V](val), ok
+}
+
+func (self *Typed[K, V]) Has(key K) bool {
+	return self.trie.Has([]byte(key))
+}
+
+func (self *Typed[K, V]) Delete(key K) bool {
+	return self.trie.Delete([]byte(key))
+}
+
+func (self *Typed[K, V]) DeletePrefix(prefix K) int {
+	return self.trie.DeletePrefix([]byte(prefix))
+}
+
+func (self *Typed[K, V]) DeleteRange(start, end K) int {
+	return self.trie.DeleteRange([]byte(start), []byte(end))
+}
+
+func (self *Typed[K, V]) Len() int {
+	return self.trie.Len()
+}
+
+func (self *Typed[K, V]) CountPrefix(prefix K) int {
+	return self.trie.CountPrefix([]byte(prefix))
+}
+
+func (self *Typed[K, V]) CountRange(start, end K) int {
+	return self.trie.CountRange([]byte(start), []byte(end))
+}
+
+func (self *Typed[K, V]) Range(start, end K) []TypedKV[K, V] {
+	return self.RangeN(start, end, -1)
+}
+
+func (self *Typed[K, V]) RangeN(start, end K, n int) []TypedKV[K, V] {
+	return self.OffsetRangeN(K(""), start, end, n)
+}
+
+func (self *Typed[K, V]) OffsetRangeN(offset, start, end K, n int) []TypedKV[K, V] {
+	return typedKVs[K, V](self.trie.OffsetRangeKV([]byte(offset), []byte(start), []byte(end), n))
+}
+
+// ReverseRangeN is RangeN from the end backwards, so the results come back in
+// reverse key order.
+func (self *Typed[K, V]) ReverseRangeN(start, end K, n int) []TypedKV[K, V] {
+	return typedKVs[K, V](self.trie.ReverseRangeKV([]byte(start), []byte(end), n))
+}
+
+// BoundedRangeN is OffsetRangeN with more say over where the range starts and
+// ends.
+func (self *Typed[K, V]) BoundedRangeN(offset K, bounds Bounds, n int) []TypedKV[K, V] {
+	return typedKVs[K, V](self.trie.BoundedRangeKV([]byte(offset), bounds, n))
+}
+
+func (self *Typed[K, V]) ReverseBoundedRangeN(bounds Bounds, n int) []TypedKV[K, V] {
+	return typedKVs[K, V](self.trie.ReverseBoundedRangeKV(bounds, n))
+}
+
+func (self *Typed[K, V]) Prefix(prefix K) []TypedKV[K, V] {
+	return self.PrefixN(prefix, -1)
+}
+
+func (self *Typed[K, V]) PrefixN(prefix K, n int) []TypedKV[K, V] {
+	return self.OffsetPrefixN(K(""), prefix, n)
+}
+
+func (self *Typed[K, V]) OffsetPrefixN(offset, prefix K, n int) []TypedKV[K, V] {
+	return typedKVs[K, V](self.trie.OffsetPrefixKV([]byte(offset), []byte(prefix), n))
+}
+
+func (self *Typed[K, V]) ReversePrefixN(prefix K, n int) []TypedKV[K, V] {
+	return typedKVs[K, V](self.trie.ReversePrefixKV([]byte(prefix), n))
+}
+
+// LongestPrefix returns the longest key in the trie that key starts with, along
+// with its value.
+func (self *Typed[K, V]) LongestPrefix(key K) (K, V, bool) {
+	return typedEntry[K, V](self.trie.LongestPrefix([]byte(key)))
+}
+
+// AllPrefixesOf returns every key in the trie that key starts with, shortest
+// first.
+func (self *Typed[K, V]) AllPrefixesOf(key K) []TypedKV[K, V] {
+	vals := self.trie.AllPrefixesOf([]byte(key))
+	res := make([]TypedKV[K, V], 0, len(vals))
+
+	for n := 0; n <= len(key); n++ {
+		if val, ok := vals[string(key[:n])]; ok {
+			res = append(res, TypedKV[K, V]{K(string(key[:n])), typedValue[V](val)})
+		}
+	}
+
+	return res
+}
+
+func (self *Typed[K, V]) Min() (K, V, bool) {
+	return typedEntry[K, V](self.trie.Min())
+}
+
+func (self *Typed[K, V]) Max() (K, V, bool) {
+	return typedEntry[K, V](self.trie.Max())
+}
+
+func (self *Typed[K, V]) Floor(key K) (K, V, bool) {
+	return typedEntry[K, V](self.trie.Floor([]byte(key)))
+}
+
+func (self *Typed[K, V]) Ceiling(key K) (K, V, bool) {
+	return typedEntry[K, V](self.trie.Ceiling([]byte(key)))
+}
+
+func (self *Typed[K, V]) Prev(key K) (K, V, bool) {
+	return typedEntry[K, V](self.trie.Prev([]byte(key)))
+}
+
+func (self *Typed[K, V]) Next(key K) (K, V, bool) {
+	return typedEntry[K, V](self.trie.Next([]byte(key)))
+}
+
+func (self *Typed[K, V]) Rank(key K) int {
+	return self.trie.Rank([]byte(key))
+}
+
+func (self *Typed[K, V]) Select(i int) (K, V, bool) {
+	return typedEntry[K, V](self.trie.Select(i))
+}
+
+// TopK returns the k heaviest keys that start with prefix, heaviest first, or
+// all of them if k is negative.
+func (self *Typed[K, V]) TopK(prefix K, k int) []TypedCompletion[K, V] {
+	completions := self.trie.TopK([]byte(prefix), k)
+	res := make([]TypedCompletion[K, V], len(completions))
+
+	for i, c := range completions {
+		res[i] = TypedCompletion[K, V]{K(c.Key), typedValue[V](c.Value), c.Weight}
+	}
+
+	return res
+}
+
+func typedPage[K Key, V any](page Page, err error) (TypedPage[K, V], error) {
+	return TypedPage[K, V]{typedKVs[K, V](page.Items), page.Cursor}, err
+}
+
+// RangePage returns up to n keys between start and end, inclusive, starting
+// after cursor. The cursors are the same as the ones Trie hands out.
+func (self *Typed[K, V]) RangePage(start, end K, cursor string, n int) (TypedPage[K, V], error) {
+	return typedPage[K, V](self.trie.RangePage([]byte(start), []byte(end), cursor, n))
+}
+
+func (self *Typed[K, V]) BoundedRangePage(bounds Bounds, cursor string, n int) (TypedPage[K, V], error) {
+	return typedPage[K, V](self.trie.BoundedRangePage(bounds, cursor, n))
+}
+
+func (self *Typed[K, V]) PrefixPage(prefix K, cursor string, n int) (TypedPage[K, V], error) {
+	return typedPage[K, V](self.trie.PrefixPage([]byte(prefix), cursor, n))
+}
+
+// Sub returns a view of the keys that start with prefix, with the prefix taken
+// off.
+func (self *Typed[K, V]) Sub(prefix K) *Typed[K, V] {
+	return NewTypedFrom[K, V](self.trie.Sub([]byte(prefix)))
+}
+
+func (self *Typed[K, V]) Iterator() *TypedIterator[K, V] {
+	return &TypedIterator[K, V]{self.trie.Iterator()}
+}
+
+func (self *Typed[K, V]) ReverseIterator() *TypedIterator[K, V] {
+	return &TypedIterator[K, V]{self.trie.ReverseIterator()}
+}
+
+// A TypedIterator is an Iterator for a Typed trie. Like Iterator, []byte keys
+// are only good until the iterator is moved again.
+type TypedIterator[K Key, V any] struct {
+	it Iterator
+}
+
+func (self *TypedIterator[K, V]) Seek(key K) {
+	self.it.Seek([]byte(key))
+}
+
+func (self *TypedIterator[K, V]) Next() {
+	self.it.Next()
+}
+
+func (self *TypedIterator[K, V]) Valid() bool {
+	return self.it.Valid()
+}
+
+func (self *TypedIterator[K, V]) Key() K {
+	return K(self.it.Key())
+}
+
+func (self *TypedIterator[K, V]) Value() V {
+	return typedValue[V](self.it.Value())
+}
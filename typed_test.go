@@ -0,0 +1,291 @@
+package trie
+
+import (
+	"testing"
+)
+
+type testPoint struct {
+	X, Y int
+}
+
+func TestTypedLookup(t *testing.T) {
+	trie := NewTyped[string, int]()
+	trie.Insert("test1", 1)
+	trie.Insert("test2", 2)
+
+	val, ok := trie.Get("test2")
+
+	if !ok {
+		t.Fatalf(`Expected "test2" to be found.`)
+	}
+
+	if val != 2 {
+		t.Fatalf(`Expected "test2" to be 2, got %d.`, val)
+	}
+
+	val, ok = trie.Get("test3")
+
+	if ok {
+		t.Fatalf(`Expected "test3" not to be found, got %d.`, val)
+	}
+
+	if val := trie.Lookup("test1"); val != 1 {
+		t.Fatalf(`Expected "test1" to be 1, got %d.`, val)
+	}
+}
+
+func TestTypedByteKeys(t *testing.T) {
+	trie := NewTyped[[]byte, testPoint]()
+	trie.Insert([]byte("table2#test1"), testPoint{1, 2})
+	trie.Insert([]byte("table2#test2"), testPoint{3, 4})
+	trie.Insert([]byte("table3#test1"), testPoint{5, 6})
+
+	vals := trie.Prefix([]byte("table2"))
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+
+	if string(vals[1].Key) != "table2#test2" || vals[1].Value.Y != 4 {
+		t.Fatalf(`Expected "table2#test2" to be {3 4}, got %v.`, vals[1])
+	}
+}
+
+func TestTypedRange(t *testing.T) {
+	trie := NewTyped[string, string]()
+	trie.Insert("20140901", "first")
+	trie.Insert("20140902", "second")
+	trie.Insert("20140903", "third")
+
+	vals := trie.Range("20140902", "20140903")
+
+	if len(vals) != 2 {
+		t.Fatalf(`Expected length of val to be 2, got %d.`, len(vals))
+	}
+
+	if vals[1].Key != "20140903" || vals[1].Value != "third" {
+		t.Fatalf(`Expected "20140903" to be "third", got %v.`, vals[1])
+	}
+
+	trie.Delete("20140903")
+	vals = trie.ReverseRangeN("20140901", "20140903", 1)
+
+	if len(vals) != 1 || vals[0].Key != "20140902" || vals[0].Value != "second" {
+		t.Fatalf(`Expected "20140902" to be "second", got %v.`, vals)
+	}
+}
+
+func TestTypedFrom(t *testing.T) {
+	untyped := New()
+	untyped.Insert([]byte("test1"), 1)
+
+	trie := NewTypedFrom[string, int](untyped)
+
+	if val, ok := trie.Get("test1"); !ok || val != 1 {
+		t.Fatalf(`Expected "test1" to be 1, got %d.`, val)
+	}
+
+	if trie.Trie() != untyped {
+		t.Fatalf(`Expected Trie to be the trie we started with.`)
+	}
+}
+
+func TestTypedNilValues(t *testing.T) {
+	trie := NewTyped[string, error]()
+	trie.Insert("ok", nil)
+
+	val, ok := trie.Get("ok")
+
+	if !ok {
+		t.Fatalf(`Expected "ok" to be found.`)
+	}
+
+	if val != nil {
+		t.Fatalf(`Expected "ok" to be nil, got %v.`, val)
+	}
+
+	if len(trie.Prefix("o")) != 1 {
+		t.Fatalf(`Expected "ok" to show up in the prefix scan.`)
+	}
+}
+
+func TestTypedBackends(t *testing.T) {
+	for _, trie := range []*Typed[string, int]{
+		NewTyped[string, int](WithRadix()),
+		NewTyped[string, int](WithART()),
+		NewTypedFrom[string, int](NewConcurrent()),
+	} {
+		trie.Insert("test1", 1)
+		trie.Insert("test2", 2)
+
+		if val, ok := trie.Get("test2"); !ok || val != 2 {
+			t.Fatalf(`Expected "test2" to be 2, got %d.`, val)
+		}
+
+		if vals := trie.Prefix("test"); len(vals) != 2 {
+			t.Fatalf(`Expected length of vals to be 2, got %d.`, len(vals))
+		}
+	}
+}
+
+func TestTypedLongestPrefix(t *testing.T) {
+	trie := NewTyped[string, int]()
+	trie.Insert("/api", 1)
+	trie.Insert("/api/users", 2)
+
+	key, val, ok := trie.LongestPrefix("/api/users/7")
+
+	if !ok || key != "/api/users" || val != 2 {
+		t.Fatalf(`Expected "/api/users" to match with 2, got %q and %d.`, key, val)
+	}
+
+	if vals := trie.AllPrefixesOf("/api/users/7"); len(vals) != 2 || vals[0].Key != "/api" || vals[0].Value != 1 {
+		t.Fatalf(`Expected both prefixes, shortest first, got %v.`, vals)
+	}
+}
+
+func TestTypedCounts(t *testing.T) {
+	trie := NewTyped[[]byte, int]()
+	trie.Insert([]byte("a"), 1)
+	trie.Insert([]byte("ab"), 2)
+	trie.Insert([]byte("abc"), 3)
+	trie.Insert([]byte("b"), 4)
+
+	if trie.Len() != 4 || trie.CountPrefix([]byte("ab")) != 2 || trie.CountRange([]byte("ab"), []byte("b")) != 3 {
+		t.Fatalf(`Expected 4 keys, 2 under "ab" and 3 from "ab" to "b".`)
+	}
+
+	if n := trie.DeletePrefix([]byte("ab")); n != 2 || trie.Len() != 2 {
+		t.Fatalf(`Expected to delete 2 keys under "ab", deleted %d.`, n)
+	}
+
+	if n := trie.DeleteRange([]byte("a"), []byte("b")); n != 2 || trie.Len() != 0 {
+		t.Fatalf(`Expected to delete the last 2 keys, deleted %d.`, n)
+	}
+}
+
+func TestTypedIterator(t *testing.T) {
+	trie := NewTyped[string, int]()
+	trie.Insert("a", 1)
+	trie.Insert("b", 2)
+	trie.Insert("c", 3)
+
+	keys := ""
+	sum := 0
+
+	for it := trie.Iterator(); it.Valid(); it.Next() {
+		keys += it.Key()
+		sum += it.Value()
+	}
+
+	if keys != "abc" || sum != 6 {
+		t.Fatalf(`Expected "abc" adding up to 6, got %q and %d.`, keys, sum)
+	}
+
+	it := trie.ReverseIterator()
+	it.Seek("bb")
+
+	if !it.Valid() || it.Key() != "b" || it.Value() != 2 {
+		t.Fatalf(`Expected to seek back to "b".`)
+	}
+}
+
+func TestTypedNavigation(t *testing.T) {
+	trie := NewTyped[string, int]()
+	trie.Insert("b", 2)
+	trie.Insert("d", 4)
+	trie.Insert("f", 6)
+
+	if key, val, ok := trie.Min(); !ok || key != "b" || val != 2 {
+		t.Fatalf(`Expected the min to be "b", got %q.`, key)
+	}
+
+	if key, _, ok := trie.Max(); !ok || key != "f" {
+		t.Fatalf(`Expected the max to be "f", got %q.`, key)
+	}
+
+	if key, _, _ := trie.Floor("c"); key != "b" {
+		t.Fatalf(`Expected the floor of "c" to be "b", got %q.`, key)
+	}
+
+	if key, _, _ := trie.Ceiling("c"); key != "d" {
+		t.Fatalf(`Expected the ceiling of "c" to be "d", got %q.`, key)
+	}
+
+	if key, _, _ := trie.Prev("d"); key != "b" {
+		t.Fatalf(`Expected the key before "d" to be "b", got %q.`, key)
+	}
+
+	if key, _, _ := trie.Next("d"); key != "f" {
+		t.Fatalf(`Expected the key after "d" to be "f", got %q.`, key)
+	}
+
+	if r := trie.Rank("e"); r != 2 {
+		t.Fatalf(`Expected the rank of "e" to be 2, got %d.`, r)
+	}
+
+	if key, val, ok := trie.Select(1); !ok || key != "d" || val != 4 {
+		t.Fatalf(`Expected key 1 to be "d", got %q.`, key)
+	}
+
+	if vals := trie.BoundedRangeN("", Bounds{Start: ExclusiveBound([]byte("b"))}, -1); len(vals) != 2 || vals[0].Key != "d" {
+		t.Fatalf(`Expected everything after "b", got %v.`, vals)
+	}
+}
+
+func TestTypedTopK(t *testing.T) {
+	trie := NewTyped[string, string]()
+	trie.InsertWeighted("car", "Car", 5)
+	trie.InsertWeighted("cart", "Cart", 9)
+	trie.Insert("cab", "Cab")
+
+	res := trie.TopK("ca", 2)
+
+	if len(res) != 2 || res[0].Key != "cart" || res[0].Value != "Cart" || res[0].Weight != 9 || res[1].Key != "car" {
+		t.Fatalf(`Expected "cart" then "car", got %v.`, res)
+	}
+}
+
+func TestTypedPages(t *testing.T) {
+	trie := NewTyped[string, int]()
+
+	for i, key := range []string{"a1", "a2", "a3", "b1"} {
+		trie.Insert(key, i)
+	}
+
+	page, err := trie.PrefixPage("a", "", 2)
+
+	if err != nil || len(page.Items) != 2 || page.Items[1].Key != "a2" {
+		t.Fatalf(`Expected "a1" and "a2", got %v and %v.`, page.Items, err)
+	}
+
+	page, err = trie.PrefixPage("a", page.Cursor, 2)
+
+	if err != nil || len(page.Items) != 1 || page.Items[0].Key != "a3" || page.Cursor != "" {
+		t.Fatalf(`Expected only "a3", got %v and %v.`, page.Items, err)
+	}
+
+	page, err = trie.RangePage("a2", "b1", "", -1)
+
+	if err != nil || len(page.Items) != 3 || page.Items[2].Value != 3 {
+		t.Fatalf(`Expected "a2" to "b1", got %v and %v.`, page.Items, err)
+	}
+}
+
+func TestTypedSub(t *testing.T) {
+	trie := NewTyped[string, int]()
+	trie.Insert("users/1", 1)
+	trie.Insert("users/2", 2)
+	trie.Insert("groups/1", 3)
+
+	users := trie.Sub("users/")
+	users.Insert("3", 4)
+
+	if users.Len() != 3 || trie.Lookup("users/3") != 4 {
+		t.Fatalf(`Expected writes to the Sub to show up in the trie.`)
+	}
+
+	if vals := users.Prefix(""); len(vals) != 3 || vals[0].Key != "1" {
+		t.Fatalf(`Expected the prefix to come off, got %v.`, vals)
+	}
+}